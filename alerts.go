@@ -0,0 +1,340 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Kinds of alert rules
+const (
+	// The mid price of the pair on the exchange
+	alertPrice = "price"
+	// Change of the mid price over the window, in percent
+	alertChange = "change"
+	// Difference between the mid price and the index, in percent
+	alertSpread = "spread"
+)
+
+// Operators of alert rules. Rules only fire when the condition starts to
+// hold, not on every cycle for as long as it holds.
+const (
+	alertAbove   = "above"
+	alertBelow   = "below"
+	alertCrosses = "crosses"
+)
+
+// Where the rules came from
+const (
+	alertSourceConfig = "config"
+	alertSourceAPI    = "api"
+)
+
+// Default window of change rules
+const defaultAlertWindow = 24 * time.Hour
+
+// Last value seen by each rule, keyed by rule id
+var (
+	alertValuesLock sync.Mutex
+	alertValues     = make(map[int64]float64)
+)
+
+// Checks that a rule is complete and fills in its defaults
+func validateAlertRule(r *AlertRule) error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Kind = strings.ToLower(r.Kind)
+	r.Operator = strings.ToLower(r.Operator)
+
+	if len(r.Name) == 0 {
+		return errors.New("name is required")
+	}
+	if len(r.Exchange) == 0 {
+		return errors.New("exchange is required")
+	}
+	if e, err := lookupExchange(r.Exchange); err == nil {
+		r.Exchange = e.Name()
+	}
+
+	pair, err := parsePair(r.Pair)
+	if err != nil {
+		return errors.New("pair: " + err.Error())
+	}
+	r.Pair = pair.String()
+
+	switch r.Kind {
+	case alertPrice, alertSpread:
+	case alertChange:
+		if r.Window <= 0 {
+			r.Window = jsonDuration(defaultAlertWindow)
+		}
+	default:
+		return errors.New("kind must be one of price, change or spread")
+	}
+
+	if len(r.IndexPair) > 0 {
+		if r.Kind != alertSpread {
+			return errors.New("indexPair is only used by spread rules")
+		}
+		indexPair, err := parsePair(r.IndexPair)
+		if err != nil {
+			return errors.New("indexPair: " + err.Error())
+		}
+		r.IndexPair = indexPair.String()
+	}
+
+	switch r.Operator {
+	case alertAbove, alertBelow, alertCrosses:
+	default:
+		return errors.New("operator must be one of above, below or crosses")
+	}
+
+	u, err := url.Parse(r.Webhook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return errors.New("webhook must be an http or https URL")
+	}
+
+	return nil
+}
+
+// Evaluates the enabled rules of an exchange, after its quotes have been
+// written to the DB
+func evaluateAlerts(exchange string) {
+	rules, err := store.AlertRules()
+	if err != nil {
+		log.Warning("%q\n", err)
+		return
+	}
+
+	now := time.Now()
+	for _, r := range rules {
+		if r.Enabled && strings.EqualFold(r.Exchange, exchange) {
+			evaluateAlert(r, now)
+		}
+	}
+}
+
+// Computes the value of a rule and fires its webhook if the condition
+// started to hold since the last evaluation
+func evaluateAlert(r *AlertRule, now time.Time) {
+	value, err := alertValue(r, now)
+	if err != nil {
+		log.Debugf("Alert %s: %s", r.Name, err.Error())
+		return
+	}
+
+	alertValuesLock.Lock()
+	previous, seen := alertValues[r.ID]
+	alertValues[r.ID] = value
+	alertValuesLock.Unlock()
+
+	// Nothing to compare against on the first evaluation
+	if !seen {
+		return
+	}
+
+	rose := previous <= r.Threshold && value > r.Threshold
+	fell := previous >= r.Threshold && value < r.Threshold
+
+	var fired bool
+	switch r.Operator {
+	case alertAbove:
+		fired = rose
+	case alertBelow:
+		fired = fell
+	case alertCrosses:
+		fired = rose || fell
+	}
+	if !fired {
+		return
+	}
+
+	event := AlertEvent{
+		Rule:        r,
+		Value:       value,
+		Previous:    previous,
+		Message:     alertMessage(r, value),
+		TriggeredAt: now.UTC().Format(time.RFC3339),
+	}
+	log.Noticef("Alert %s: %s", r.Name, event.Message)
+
+	go deliverAlert(r.Webhook, r.Secret, event)
+}
+
+// Computes the value a rule compares against its threshold
+func alertValue(r *AlertRule, now time.Time) (float64, error) {
+	pair, err := parsePair(r.Pair)
+	if err != nil {
+		return 0, err
+	}
+	market := Market{Exchange: r.Exchange, Pair: pair}
+
+	latest, err := store.Latest(market)
+	if err != nil {
+		return 0, err
+	}
+
+	switch r.Kind {
+	case alertPrice:
+		return latest.Average, nil
+
+	case alertChange:
+		before, err := store.QuoteAt(market, now.Add(-time.Duration(r.Window)).Unix())
+		if err != nil {
+			return 0, errors.New("No quote " + time.Duration(r.Window).String() + " ago")
+		}
+		if before.Average <= 0 {
+			return 0, errors.New("No price " + time.Duration(r.Window).String() + " ago")
+		}
+		return (latest.Average/before.Average - 1) * 100, nil
+
+	case alertSpread:
+		index, err := alertIndexPrice(pair, r.IndexPair, now)
+		if err != nil {
+			return 0, err
+		}
+		return (latest.Average/index - 1) * 100, nil
+	}
+
+	return 0, errors.New("Unknown kind " + r.Kind)
+}
+
+// Returns the index price of a pair. When indexPair is set, its index is
+// converted into the quote currency of pair with the fiat rates.
+func alertIndexPrice(pair Pair, indexPair string, now time.Time) (float64, error) {
+	target := pair
+	if len(indexPair) > 0 {
+		var err error
+		if target, err = parsePair(indexPair); err != nil {
+			return 0, err
+		}
+	}
+
+	quotes, err := store.LatestQuotes(target)
+	if err != nil {
+		return 0, err
+	}
+	index, err := computeIndex(target, quotes, indexVWAP, defaultIndexTrim, 0, now)
+	if err != nil {
+		return 0, err
+	}
+
+	if target.Quote == pair.Quote {
+		return index.Price, nil
+	}
+
+	rate, err := currentFiatRates().rate(target.Quote, pair.Quote)
+	if err != nil {
+		return 0, err
+	}
+	return index.Price * rate, nil
+}
+
+// Describes a fired rule
+func alertMessage(r *AlertRule, value float64) string {
+	switch r.Kind {
+	case alertChange:
+		return fmt.Sprintf("%s %s changed %.2f%% in %s, %s %.2f%%", r.Exchange, r.Pair, value, time.Duration(r.Window), r.Operator, r.Threshold)
+	case alertSpread:
+		return fmt.Sprintf("%s %s is %.2f%% from the index, %s %.2f%%", r.Exchange, r.Pair, value, r.Operator, r.Threshold)
+	}
+	return fmt.Sprintf("%s %s mid is %.8g, %s %.8g", r.Exchange, r.Pair, value, r.Operator, r.Threshold)
+}
+
+// Reads the [[alerts]] tables of the config file, warning about rules
+// that would be sent unsigned without alertSecret
+func loadConfigAlerts(alertSecret string) []*AlertRule {
+	var raw []struct {
+		Name      string
+		Kind      string
+		Exchange  string
+		Pair      string
+		Operator  string
+		Threshold float64
+		Window    string
+		IndexPair string
+		Webhook   string
+		Secret    string
+	}
+	if err := viper.UnmarshalKey("alerts", &raw); err != nil {
+		log.Warningf("alerts: %s", err.Error())
+		return nil
+	}
+
+	var rules []*AlertRule
+	for _, a := range raw {
+		r := &AlertRule{
+			Name:      a.Name,
+			Source:    alertSourceConfig,
+			Kind:      a.Kind,
+			Exchange:  a.Exchange,
+			Pair:      a.Pair,
+			Operator:  a.Operator,
+			Threshold: a.Threshold,
+			IndexPair: a.IndexPair,
+			Webhook:   a.Webhook,
+			Secret:    a.Secret,
+			Enabled:   true,
+		}
+		if len(a.Window) > 0 {
+			window, err := time.ParseDuration(a.Window)
+			if err != nil {
+				log.Warningf("alerts %s: window: %s", a.Name, err.Error())
+				continue
+			}
+			r.Window = jsonDuration(window)
+		}
+		if err := validateAlertRule(r); err != nil {
+			log.Warningf("alerts %s: %s", a.Name, err.Error())
+			continue
+		}
+		if len(r.Secret) == 0 && len(alertSecret) == 0 {
+			log.Warningf("alerts %s: no secret and alertSecret is not set, its webhooks won't be signed", a.Name)
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// Writes the rules of the config file to the DB. Rules added through the
+// API are left alone, and rules removed from the config file are deleted.
+func syncConfigAlerts(rules []*AlertRule) error {
+	existing, err := store.AlertRules()
+	if err != nil {
+		return err
+	}
+
+	byName := make(map[string]*AlertRule)
+	for _, r := range existing {
+		byName[r.Name] = r
+	}
+
+	keep := make(map[string]bool)
+	for _, r := range rules {
+		keep[r.Name] = true
+
+		if current, ok := byName[r.Name]; ok {
+			if current.Source != alertSourceConfig {
+				log.Warningf("Alert %s is already defined through the API, skipping the config file rule", r.Name)
+				continue
+			}
+			r.ID = current.ID
+		}
+		if err := store.SaveAlertRule(r); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range existing {
+		if r.Source == alertSourceConfig && !keep[r.Name] {
+			if err := store.DeleteAlertRule(r.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
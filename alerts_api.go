@@ -0,0 +1,193 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Only lets requests bearing the alertToken from the config file through.
+// Without a token, alert rules can't be changed through the API at all.
+func requireAlertToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if len(config.AlertToken) == 0 {
+			http.Error(w, "Set alertToken in the config file to manage alert rules through the API", http.StatusForbidden)
+			return
+		}
+
+		token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(config.AlertToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Invalid or missing alert token", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, req)
+	}
+}
+
+// Blanks the secrets of rules before they are returned
+func hideAlertSecrets(rules ...*AlertRule) {
+	for _, r := range rules {
+		r.Secret = ""
+	}
+}
+
+// Reads the id of the rule from the URL
+func alertRuleID(req *http.Request) (int64, error) {
+	return strconv.ParseInt(mux.Vars(req)["id"], 10, 64)
+}
+
+// Get every alert rule
+func listAlertRules(w http.ResponseWriter, req *http.Request) {
+	data, err := store.AlertRules()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	hideAlertSecrets(data...)
+
+	log.Infof("Called: alerts\n")
+
+	json.NewEncoder(w).Encode(data)
+}
+
+// Get a single alert rule
+func getAlertRule(w http.ResponseWriter, req *http.Request) {
+	id, err := alertRuleID(req)
+	if err != nil {
+		http.Error(w, "id must be a number", http.StatusBadRequest)
+		return
+	}
+
+	data, err := store.AlertRule(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	hideAlertSecrets(data)
+
+	json.NewEncoder(w).Encode(data)
+}
+
+// Add an alert rule
+func createAlertRule(w http.ResponseWriter, req *http.Request) {
+	r := &AlertRule{Enabled: true}
+	if err := json.NewDecoder(req.Body).Decode(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.ID = 0
+	r.Source = alertSourceAPI
+
+	if err := validateAlertRule(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkWebhookHost(r.Webhook); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := store.SaveAlertRule(r); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	hideAlertSecrets(r)
+
+	log.Infof("Created alert %s\n", r.Name)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(r)
+}
+
+// Replace an alert rule. Rules from the config file can only be changed
+// there. Leaving the secret out keeps the current one.
+func updateAlertRule(w http.ResponseWriter, req *http.Request) {
+	id, err := alertRuleID(req)
+	if err != nil {
+		http.Error(w, "id must be a number", http.StatusBadRequest)
+		return
+	}
+
+	current, err := store.AlertRule(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if current.Source == alertSourceConfig {
+		http.Error(w, "Alert rule is defined in the config file", http.StatusConflict)
+		return
+	}
+
+	r := &AlertRule{Enabled: true}
+	if err := json.NewDecoder(req.Body).Decode(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.ID = id
+	r.Source = alertSourceAPI
+	if len(r.Secret) == 0 {
+		r.Secret = current.Secret
+	}
+
+	if err := validateAlertRule(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkWebhookHost(r.Webhook); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := store.SaveAlertRule(r); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	// Start the rule afresh
+	alertValuesLock.Lock()
+	delete(alertValues, id)
+	alertValuesLock.Unlock()
+
+	hideAlertSecrets(r)
+
+	log.Infof("Updated alert %s\n", r.Name)
+
+	json.NewEncoder(w).Encode(r)
+}
+
+// Remove an alert rule. Rules from the config file can only be removed
+// there.
+func deleteAlertRule(w http.ResponseWriter, req *http.Request) {
+	id, err := alertRuleID(req)
+	if err != nil {
+		http.Error(w, "id must be a number", http.StatusBadRequest)
+		return
+	}
+
+	current, err := store.AlertRule(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if current.Source == alertSourceConfig {
+		http.Error(w, "Alert rule is defined in the config file", http.StatusConflict)
+		return
+	}
+
+	if err := store.DeleteAlertRule(id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	alertValuesLock.Lock()
+	delete(alertValues, id)
+	alertValuesLock.Unlock()
+
+	log.Infof("Deleted alert %s\n", current.Name)
+
+	w.WriteHeader(http.StatusNoContent)
+}
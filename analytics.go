@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Computes and stores the arbitrage spreads and premiums on the analytics
+// interval, for as long as the service runs
+func startAnalytics() {
+	go func() {
+		for {
+			runAnalytics()
+			time.Sleep(config.Analytics.Interval)
+		}
+	}()
+}
+
+// Computes the current spreads and premiums and writes them to the DB
+func runAnalytics() {
+	now := time.Now()
+
+	spreads, premiums, err := computeAnalytics(now)
+	if err != nil {
+		log.Warning("%q\n", err)
+		return
+	}
+
+	if err := store.InsertArbitrage(now.Unix(), spreads); err != nil {
+		log.Warning("%q\n", err)
+	}
+	if err := store.InsertPremiums(now.Unix(), premiums); err != nil {
+		log.Warning("%q\n", err)
+	}
+
+	log.Noticef("Ran Analytics, %d spreads and %d premiums", len(spreads), len(premiums))
+}
+
+// Computes the current spreads and premiums from the latest quotes
+func computeAnalytics(now time.Time) ([]*ArbitrageSpread, []*Premium, error) {
+	quotes, err := store.LatestQuotes(Pair{})
+	if err != nil {
+		return nil, nil, err
+	}
+	quotes = freshQuotes(quotes, now)
+
+	spreads := computeArbitrage(quotes, now)
+	premiums := computePremiums(quotes, config.Analytics, currentFiatRates(), now)
+	return spreads, premiums, nil
+}
+
+// Leaves out quotes that are stale or have no price
+func freshQuotes(quotes []*APIStruct, now time.Time) []*APIStruct {
+	var resp []*APIStruct
+	for _, q := range quotes {
+		if len(q.Base) == 0 || len(q.Quote) == 0 || q.Ask <= 0 || q.Bid <= 0 {
+			continue
+		}
+		if now.Sub(time.Unix(int64(q.Timestamp), 0)) > quoteMaxAge(q.Exchange, 0) {
+			continue
+		}
+		resp = append(resp, q)
+	}
+	return resp
+}
+
+// For every pair listed on more than one exchange, finds the cheapest
+// exchange to buy on and the dearest to sell on. A positive spread means
+// the pair can be bought on one exchange and sold on the other at a profit.
+func computeArbitrage(quotes []*APIStruct, now time.Time) []*ArbitrageSpread {
+	byPair := make(map[Pair][]*APIStruct)
+	for _, q := range quotes {
+		pair := Pair{Base: q.Base, Quote: q.Quote}
+		byPair[pair] = append(byPair[pair], q)
+	}
+
+	resp := []*ArbitrageSpread{}
+	for pair, listed := range byPair {
+		if len(listed) < 2 {
+			continue
+		}
+
+		buy, sell := listed[0], listed[0]
+		for _, q := range listed[1:] {
+			if q.Ask < buy.Ask {
+				buy = q
+			}
+			if q.Bid > sell.Bid {
+				sell = q
+			}
+		}
+
+		resp = append(resp, &ArbitrageSpread{
+			Pair:         pair.String(),
+			Base:         pair.Base,
+			Quote:        pair.Quote,
+			BuyExchange:  buy.Exchange,
+			BuyPrice:     buy.Ask,
+			SellExchange: sell.Exchange,
+			SellPrice:    sell.Bid,
+			Spread:       roundPercent((sell.Bid - buy.Ask) / buy.Ask * 100),
+			Exchanges:    len(listed),
+			DateUpdated:  now.UTC().Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	// Biggest opportunities first
+	sort.Slice(resp, func(i, j int) bool { return resp[i].Spread > resp[j].Spread })
+	return resp
+}
+
+// Compares every quote of the reference pair's base currency in another
+// fiat currency against the reference quote, converted with the fiat
+// rates. A positive premium means the asset is dearer on that exchange.
+func computePremiums(quotes []*APIStruct, cfg AnalyticsConfig, rates *fiatRates, now time.Time) []*Premium {
+	resp := []*Premium{}
+
+	reference, err := parsePair(cfg.ReferencePair)
+	if err != nil {
+		log.Warningf("analytics.referencePair: %s", err.Error())
+		return resp
+	}
+
+	// Find the reference quote
+	var ref *APIStruct
+	for _, q := range quotes {
+		if strings.EqualFold(q.Exchange, cfg.ReferenceExchange) && q.Base == reference.Base && q.Quote == reference.Quote {
+			ref = q
+			break
+		}
+	}
+	if ref == nil {
+		log.Warningf("No fresh reference quote for %s %s", cfg.ReferenceExchange, reference)
+		return resp
+	}
+
+	for _, q := range quotes {
+		if q == ref || q.Base != reference.Base {
+			continue
+		}
+
+		// Only fiat currencies have a rate
+		rate, err := rates.rate(reference.Quote, q.Quote)
+		if err != nil {
+			continue
+		}
+
+		converted := ref.Average * rate
+		resp = append(resp, &Premium{
+			Exchange:                q.Exchange,
+			Pair:                    q.Base + "-" + q.Quote,
+			Base:                    q.Base,
+			Quote:                   q.Quote,
+			Price:                   q.Average,
+			ReferenceExchange:       ref.Exchange,
+			ReferencePair:           reference.String(),
+			ReferencePrice:          ref.Average,
+			FiatRate:                rate,
+			ConvertedReferencePrice: math.Round(converted*1e8) / 1e8,
+			Premium:                 roundPercent((q.Average/converted - 1) * 100),
+			DateUpdated:             now.UTC().Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	sort.Slice(resp, func(i, j int) bool { return resp[i].Premium > resp[j].Premium })
+	return resp
+}
+
+// Rounds a percentage to 4 decimals
+func roundPercent(value float64) float64 {
+	return math.Round(value*1e4) / 1e4
+}
+
+// Get the current cross-exchange spreads
+func getArbitrage(w http.ResponseWriter, req *http.Request) {
+	spreads, _, err := computeAnalytics(time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Infof("Called: arbitrage\n")
+
+	json.NewEncoder(w).Encode(spreads)
+}
+
+// Get the current regional premiums
+func getPremiums(w http.ResponseWriter, req *http.Request) {
+	_, premiums, err := computeAnalytics(time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Infof("Called: premium\n")
+
+	json.NewEncoder(w).Encode(premiums)
+}
+
+// Get the stored spreads of a pair between from and to, oldest first
+func getArbitrageHistory(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	pair, err := parsePair(query.Get("pair"))
+	if err != nil {
+		http.Error(w, "pair: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, to, limit, err := parseAnalyticsRange(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, err := store.ArbitrageHistory(pair, from, to, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Infof("Called: arbitrage history %s\n", pair)
+
+	json.NewEncoder(w).Encode(data)
+}
+
+// Get the stored premiums of an exchange and pair between from and to,
+// oldest first
+func getPremiumHistory(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	if len(query.Get("exchange")) == 0 {
+		http.Error(w, "exchange is required", http.StatusBadRequest)
+		return
+	}
+	e, err := lookupExchange(query.Get("exchange"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	exchange := e.Name()
+
+	pair, err := parsePair(query.Get("pair"))
+	if err != nil {
+		http.Error(w, "pair: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, to, limit, err := parseAnalyticsRange(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, err := store.PremiumHistory(exchange, pair, from, to, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Infof("Called: premium history %s %s\n", exchange, pair)
+
+	json.NewEncoder(w).Encode(data)
+}
+
+// Parses the from, to and limit parameters of the history endpoints
+func parseAnalyticsRange(req *http.Request) (from int64, to int64, limit int64, err error) {
+	query := req.URL.Query()
+
+	if from, err = parseTimeParam(query.Get("from"), 0); err != nil {
+		return 0, 0, 0, err
+	}
+	if to, err = parseTimeParam(query.Get("to"), time.Now().Unix()); err != nil {
+		return 0, 0, 0, err
+	}
+	if limit, err = parseIntParam(query.Get("limit"), defaultHistoryLimit); err != nil || limit <= 0 {
+		return 0, 0, 0, errors.New("limit must be a positive number")
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+	return from, to, limit, nil
+}
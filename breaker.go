@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// State of a circuit breaker
+type breakerState int
+
+const (
+	// Requests flow as normal
+	breakerClosed breakerState = iota
+	// The exchange is failing and is left alone until the cooldown passes
+	breakerOpen
+	// A single probe is allowed through to see if the exchange is back
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// Stops polling an exchange after too many consecutive failures
+type circuitBreaker struct {
+	mu        sync.Mutex
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+	lastError string
+}
+
+// Breakers per exchange key
+var (
+	breakersLock sync.Mutex
+	breakers     = make(map[string]*circuitBreaker)
+)
+
+// Returns the breaker of an exchange, creating it if needed
+func breakerFor(exchange Exchange) *circuitBreaker {
+	breakersLock.Lock()
+	defer breakersLock.Unlock()
+
+	b, ok := breakers[exchange.Key()]
+	if !ok {
+		b = &circuitBreaker{}
+		breakers[exchange.Key()] = b
+	}
+	return b
+}
+
+// Reports whether a fetch may go ahead. Once the cooldown of an open
+// breaker has passed, a single probe is let through.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < config.BreakerCooldown {
+			return false
+		}
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		// The probe is still in flight
+		return false
+	}
+	return true
+}
+
+// Records a successful fetch and closes the breaker
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = breakerClosed
+	b.failures = 0
+}
+
+// Records a failed fetch, opening the breaker once the threshold is hit
+// or straight away when a probe fails. Returns true if the breaker opened.
+func (b *circuitBreaker) failure(err error) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.lastError = err.Error()
+
+	if b.state == breakerHalfOpen || (b.state == breakerClosed && b.failures >= config.BreakerThreshold) {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+		return true
+	}
+	return false
+}
+
+// Breaker state as returned by the API
+type BreakerStatus struct {
+	Exchange            string `json:"exchange"`
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	LastError           string `json:"lastError,omitempty"`
+	OpenedAt            string `json:"openedAt,omitempty"`
+	NextProbe           string `json:"nextProbe,omitempty"`
+}
+
+// Returns the state of the breaker for the API
+func (b *circuitBreaker) status(exchange string) BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	resp := BreakerStatus{
+		Exchange:            exchange,
+		State:               b.state.String(),
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastError,
+	}
+	if b.state != breakerClosed {
+		resp.OpenedAt = b.openedAt.UTC().Format(time.RFC3339)
+		resp.NextProbe = b.openedAt.Add(config.BreakerCooldown).UTC().Format(time.RFC3339)
+	}
+	return resp
+}
+
+// Get the circuit breaker state of every exchange
+func showBreakers(w http.ResponseWriter, req *http.Request) {
+	var data []BreakerStatus
+	for _, exchange := range registeredExchanges() {
+		data = append(data, breakerFor(exchange).status(exchange.Name()))
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].Exchange < data[j].Exchange })
+
+	log.Infof("Called Breakers")
+
+	json.NewEncoder(w).Encode(data)
+}
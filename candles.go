@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Candle intervals that can be requested
+var candleIntervals = map[string]time.Duration{
+	"1m":  time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"4h":  4 * time.Hour,
+	"1d":  24 * time.Hour,
+	"1w":  7 * 24 * time.Hour,
+}
+
+// Most candles returned in a single request
+const maxCandles = 5000
+
+// Weekly candles start on a Monday, 4 days after the unix epoch
+const weekOffset = 4 * 24 * 60 * 60
+
+// Get OHLC candles for an exchange, computed from the mid price of the
+// stored ticks. The volume is the rolling 24 hour volume the exchange
+// reported with the last tick, not the volume traded within the candle.
+func getExchangeCandles(w http.ResponseWriter, req *http.Request) {
+
+	var (
+		params = mux.Vars(req)
+		query  = req.URL.Query()
+	)
+
+	market, err := parseMarket(params["exchange"], params["currencyCode"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Default to hourly candles
+	name := query.Get("interval")
+	if len(name) == 0 {
+		name = "1h"
+	}
+	interval, ok := candleIntervals[name]
+	if !ok {
+		http.Error(w, "interval must be one of 1m, 5m, 15m, 30m, 1h, 4h, 1d or 1w", http.StatusBadRequest)
+		return
+	}
+
+	// Default to the last 100 candles
+	now := time.Now().Unix()
+	to, err := parseTimeParam(query.Get("to"), now)
+	if err != nil {
+		http.Error(w, "to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := parseTimeParam(query.Get("from"), to-100*int64(interval/time.Second))
+	if err != nil {
+		http.Error(w, "from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if (to-from)/int64(interval/time.Second) > maxCandles {
+		http.Error(w, "Too many candles, use a larger interval or a shorter range", http.StatusBadRequest)
+		return
+	}
+
+	data, err := aggregateCandles(market, interval, from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Infof("Called: %s candles %s\n", market, name)
+
+	json.NewEncoder(w).Encode(data)
+}
+
+// Returns the start of the candle a timestamp falls into. Candles are
+// aligned to the unix epoch, except for weekly candles which start on
+// Monday 00:00 UTC.
+func candleStart(timestamp int64, interval time.Duration) int64 {
+	seconds := int64(interval / time.Second)
+
+	var offset int64
+	if interval == candleIntervals["1w"] {
+		offset = weekOffset
+	}
+
+	// Keep the remainder positive for timestamps before the offset
+	return timestamp - ((timestamp-offset)%seconds+seconds)%seconds
+}
+
+// Aggregates the ticks between from and to into candles. Buckets without
+// any ticks are left out.
+func aggregateCandles(market Market, interval time.Duration, from int64, to int64) (resp []*Candle, err error) {
+	resp = []*Candle{}
+
+	var candle *Candle
+	err = store.Ticks(market, from, to, func(tick Tick) error {
+		// Start a new candle when the tick falls into the next bucket
+		bucket := candleStart(tick.Timestamp, interval)
+		if candle == nil || candle.start != bucket {
+			candle = &Candle{
+				start:    bucket,
+				OpenTime: time.Unix(bucket, 0).UTC().Format(time.RFC3339),
+				Open:     tick.Price,
+				High:     tick.Price,
+				Low:      tick.Price,
+			}
+			resp = append(resp, candle)
+		}
+
+		if tick.Price > candle.High {
+			candle.High = tick.Price
+		}
+		if tick.Price < candle.Low {
+			candle.Low = tick.Price
+		}
+
+		// Exchanges report a rolling 24 hour volume, so summing it
+		// would count the same trades many times over, and the
+		// difference between ticks goes negative as trades roll off
+		candle.Close = tick.Price
+		candle.Volume = tick.Volume
+		candle.Ticks++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
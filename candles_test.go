@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCandleStart(t *testing.T) {
+	at := func(value string) int64 {
+		ts, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts.Unix()
+	}
+
+	cases := []struct {
+		interval string
+		at       string
+		want     string
+	}{
+		{"1m", "2017-06-15T10:42:31Z", "2017-06-15T10:42:00Z"},
+		{"15m", "2017-06-15T10:42:31Z", "2017-06-15T10:30:00Z"},
+		{"4h", "2017-06-15T10:42:31Z", "2017-06-15T08:00:00Z"},
+		{"1d", "2017-06-15T10:42:31Z", "2017-06-15T00:00:00Z"},
+		// A Thursday, a Sunday night and a Monday morning
+		{"1w", "2017-06-15T10:42:31Z", "2017-06-12T00:00:00Z"},
+		{"1w", "2017-06-18T23:59:59Z", "2017-06-12T00:00:00Z"},
+		{"1w", "2017-06-19T00:00:00Z", "2017-06-19T00:00:00Z"},
+		// The first days of the epoch belong to the week before
+		{"1w", "1970-01-02T00:00:00Z", "1969-12-29T00:00:00Z"},
+	}
+
+	for _, c := range cases {
+		got := candleStart(at(c.at), candleIntervals[c.interval])
+		if got != at(c.want) {
+			t.Errorf("candleStart(%s, %s) = %s, want %s", c.at, c.interval, time.Unix(got, 0).UTC().Format(time.RFC3339), c.want)
+		}
+	}
+}
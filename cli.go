@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
+)
+
+// How often serve looks for quotes written by another process
+const tailInterval = 5 * time.Second
+
+// Prints the list of commands
+func usage(out io.Writer) {
+	fmt.Fprint(out, `Usage: kyco.bitcoin.currency.tickers [--config <file>] [--data-dir <dir>] [command] [flags]
+
+Flags:
+  --config <file>              config file, or a directory holding config.toml
+  --data-dir <dir>             directory holding the sqlite database
+
+Commands:
+  run                          poll the exchanges and serve the API (the default)
+  serve                        serve the API only
+  ingest                       poll the exchanges only
+  fetch <exchange>             poll an exchange once and print its quotes without storing them
+  query <exchange> <pair>      print the latest quote, or with -history the stored quotes
+  export -o <file>             write the stored quotes to a CSV or NDJSON file
+  migrate                      apply pending schema migrations
+  config validate              check the config file and the database connection
+  config show                  print the config in effect, with secrets hidden
+
+Run a command with -h for its flags.
+`)
+}
+
+// Opens the database selected in the config file, exiting on failure
+func openDatabase() {
+	store, err = openStore(config)
+	if err != nil {
+		log.Criticalf("Could not open the database: %s", err.Error())
+		fmt.Fprintf(os.Stderr, "Could not open the database: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+// Opens the database and brings its schema up to date, exiting on failure
+func setupStore() {
+	openDatabase()
+
+	// Setup the DB tables
+	if err := store.Setup(); err != nil {
+		log.Criticalf("Could not set up the database: %s", err.Error())
+		fmt.Fprintf(os.Stderr, "Could not set up the database: %s\n", err.Error())
+		store.Close()
+		os.Exit(1)
+	}
+}
+
+// Starts polling the exchanges, along with the jobs fed by the new quotes
+func startIngest() {
+	// Store the alert rules of the config file
+	if err := syncConfigAlerts(config.Alerts); err != nil {
+		log.Warning("%q\n", err)
+	}
+
+	// Start bitcoin ticker
+	bitcoinPrices()
+
+	// Start computing spreads and premiums
+	startAnalytics()
+
+	log.Info("started polling exchanges")
+}
+
+// Feeds the streaming endpoints with quotes written by another process,
+// when serve runs alongside a separate ingest
+func startTail() {
+	go func() {
+		// Only quotes written from now on are new
+		last, err := store.LastID()
+		if err != nil {
+			log.Warning("%q\n", err)
+		}
+
+		for {
+			time.Sleep(tailInterval)
+
+			rows, err := store.QuotesAfter("", Pair{}, last, maxHistoryLimit)
+			if err != nil {
+				log.Warning("%q\n", err)
+				continue
+			}
+			if len(rows) > 0 {
+				last = rows[len(rows)-1].ID
+				recordNewestQuotes(rows)
+				quoteFeed.publish(rows)
+			}
+		}
+	}()
+}
+
+// Serves the API until the process exits
+func serveAPI() {
+	// Notify log that we are up and running
+	log.Info("started kyco.bitcoin.currency.tickers")
+
+	// Setup API
+	router := mux.NewRouter()
+	router.Use(instrumentRoutes)
+
+	// Setup Route
+	router.HandleFunc("/healthz", healthz).Methods("GET")
+	router.HandleFunc("/readyz", readyz).Methods("GET")
+	router.Handle("/metrics", metricsHandler).Methods("GET")
+	router.HandleFunc("/ws", streamWebSocket)
+	router.HandleFunc("/stream", streamEvents).Methods("GET")
+	router.HandleFunc("/export", exportQuotes).Methods("GET")
+	router.HandleFunc("/breakers", showBreakers).Methods("GET")
+	router.HandleFunc("/index/{pair}", getPriceIndex).Methods("GET")
+	router.HandleFunc("/convert", getConversion).Methods("GET")
+	router.HandleFunc("/alerts", listAlertRules).Methods("GET")
+	router.HandleFunc("/alerts", requireAlertToken(createAlertRule)).Methods("POST")
+	router.HandleFunc("/alerts/{id}", getAlertRule).Methods("GET")
+	router.HandleFunc("/alerts/{id}", requireAlertToken(updateAlertRule)).Methods("PUT")
+	router.HandleFunc("/alerts/{id}", requireAlertToken(deleteAlertRule)).Methods("DELETE")
+	router.HandleFunc("/analytics/arbitrage", getArbitrage).Methods("GET")
+	router.HandleFunc("/analytics/arbitrage/history", getArbitrageHistory).Methods("GET")
+	router.HandleFunc("/analytics/premium", getPremiums).Methods("GET")
+	router.HandleFunc("/analytics/premium/history", getPremiumHistory).Methods("GET")
+	router.HandleFunc("/{exchange}/{currencyCode}/history", getExchangeHistory).Methods("GET")
+	router.HandleFunc("/{exchange}/{currencyCode}/candles", getExchangeCandles).Methods("GET")
+	router.HandleFunc("/{exchange}/{currencyCode}", get_exchange_rate).Methods("GET")
+	router.HandleFunc("/{exchange}", show_exchange_methods).Methods("GET")
+	router.HandleFunc("/", showExchanges).Methods("GET")
+
+	// Create listen and serve
+	if err := http.ListenAndServe(":"+config.Port, router); err != nil {
+		log.Criticalf("Could not serve the API: %s", err.Error())
+		fmt.Fprintf(os.Stderr, "Could not serve the API: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+// Applies the pending migrations
+func migrateCommand() {
+	applied, err := store.Migrate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Migration failed: %s\n", err.Error())
+		store.Close()
+		os.Exit(1)
+	}
+
+	version, err := store.SchemaVersion()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not read the schema version: %s\n", err.Error())
+		store.Close()
+		os.Exit(1)
+	}
+
+	fmt.Printf("Applied %d migrations, schema is at version %d\n", applied, version)
+}
+
+// Polls a single exchange and prints its quotes without storing them,
+// eg. fetch luno
+func fetchCommand(args []string) {
+	flags := flag.NewFlagSet("fetch", flag.ExitOnError)
+	jsonFlag := flags.Bool("json", false, "print the quotes as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: fetch [-json] <exchange>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	exchange, err := lookupExchange(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fetch: %s: %s\n", err.Error(), flags.Arg(0))
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.FetchTimeout)
+	defer cancel()
+
+	quotes, err := fetchQuotes(ctx, exchange)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fetch: %s: %s\n", exchange.Name(), err.Error())
+		os.Exit(1)
+	}
+
+	if *jsonFlag {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, q := range quotes {
+			encoder.Encode(map[string]string{
+				"exchange":     q.Exchange,
+				"currencyCode": q.CurrencyCode,
+				"base":         q.Pair.Base,
+				"quote":        q.Pair.Quote,
+				"bid":          q.Bid,
+				"ask":          q.Ask,
+				"volume":       q.Volume,
+				"timestamp":    q.Timestamp,
+			})
+		}
+		return
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "EXCHANGE\tPAIR\tCURRENCY\tBID\tASK\tVOLUME\tTIMESTAMP")
+	for _, q := range quotes {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", q.Exchange, q.Pair, q.CurrencyCode, q.Bid, q.Ask, q.Volume, q.Timestamp)
+	}
+	table.Flush()
+}
+
+// Prints the latest quote of a market, or its stored quotes with -history,
+// eg. query luno BTC-ZAR or query -history -from 2017-01-01T00:00:00Z luno BTC-ZAR
+func queryCommand(args []string) {
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	historyFlag := flags.Bool("history", false, "print the stored quotes instead of the latest one")
+	fromFlag := flags.String("from", "", "oldest quote to print with -history, unix seconds or RFC3339")
+	toFlag := flags.String("to", "", "newest quote to print with -history, unix seconds or RFC3339")
+	limitFlag := flags.Int64("limit", defaultHistoryLimit, "most quotes to print with -history")
+	jsonFlag := flags.Bool("json", false, "print the quotes as JSON, as returned by the API")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: query [flags] <exchange> <currencyCode|pair>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	fail := func(format string, a ...interface{}) {
+		fmt.Fprintf(os.Stderr, format+"\n", a...)
+		store.Close()
+		os.Exit(1)
+	}
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		store.Close()
+		os.Exit(2)
+	}
+
+	exchange := flags.Arg(0)
+	if e, err := lookupExchange(exchange); err == nil {
+		exchange = e.Name()
+	}
+	market, err := parseMarket(exchange, flags.Arg(1))
+	if err != nil {
+		fail("query: %s", err.Error())
+	}
+
+	var data []*APIStruct
+	if *historyFlag {
+		from, err := parseTimeParam(*fromFlag, 0)
+		if err != nil {
+			fail("query: -from: %s", err.Error())
+		}
+		to, err := parseTimeParam(*toFlag, time.Now().Unix())
+		if err != nil {
+			fail("query: -to: %s", err.Error())
+		}
+		if *limitFlag <= 0 {
+			fail("query: -limit must be a positive number")
+		}
+		if data, err = store.History(market, from, to, 0, *limitFlag); err != nil {
+			fail("query: %s", err.Error())
+		}
+	} else {
+		latest, err := store.Latest(market)
+		if err != nil {
+			fail("query: no quote for %s", market)
+		}
+		data = []*APIStruct{latest}
+	}
+
+	if *jsonFlag {
+		encoder := json.NewEncoder(os.Stdout)
+		if *historyFlag {
+			encoder.Encode(data)
+		} else {
+			encoder.Encode(data[0])
+		}
+		return
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tEXCHANGE\tPAIR\tBID\tASK\tMID\tVOLUME\tDATE")
+	for _, row := range data {
+		pair := row.CurrencyCode
+		if len(row.Base) > 0 {
+			pair = row.Base + "-" + row.Quote
+		}
+		fmt.Fprintf(table, "%d\t%s\t%s\t%g\t%g\t%g\t%g\t%s\n", row.ID, row.Exchange, pair, row.Bid, row.Ask, row.Average, row.Volume, row.DateUpdated)
+	}
+	table.Flush()
+}
+
+// Runs the config subcommands, given the problems configInit found
+func configCommand(args []string, problems []string) {
+	if len(args) == 1 && args[0] == "show" {
+		if len(problems) > 0 {
+			for _, problem := range problems {
+				fmt.Fprintln(os.Stderr, problem)
+			}
+			os.Exit(1)
+		}
+		writeConfig(os.Stdout, config)
+		return
+	}
+
+	if len(args) != 1 || args[0] != "validate" {
+		fmt.Fprintln(os.Stderr, "Usage: config validate | config show")
+		os.Exit(2)
+	}
+
+	if len(viper.ConfigFileUsed()) == 0 {
+		problems = append(problems, "No config file found in "+configSearchPath())
+	}
+	if len(problems) == 0 {
+		problems = checkDatabase()
+	}
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintln(os.Stderr, problem)
+		}
+		os.Exit(1)
+	}
+
+	fmt.Printf("%s is valid\n", viper.ConfigFileUsed())
+}
+
+// Checks that the database in the config file can be reached
+func checkDatabase() []string {
+	db, err := openStore(config)
+	if err != nil {
+		return []string{"database: " + err.Error()}
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), readinessTimeout)
+	defer cancel()
+	if err := db.Ping(ctx); err != nil {
+		return []string{"database: " + err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Prefix of the environment variables overriding the config file
+const envPrefix = "KBCT"
+
+// Settings holding secrets. Each can also be read from a file named by
+// the same key with a File suffix, eg. apiSecretFile.
+func secretKeys() []string {
+	keys := []string{"config.alertsecret", "config.alerttoken", "database.dsn"}
+	for _, exchange := range registeredExchanges() {
+		section := "exchanges." + strings.ToLower(exchange.Key()) + "."
+		keys = append(keys, section+"apikey", section+"apisecret")
+	}
+	return keys
+}
+
+// Environment variables bound to each setting, by lower cased key
+var configEnvNames = make(map[string]string)
+
+// Lets every setting be overridden by an environment variable named after
+// its key, eg. KBCT_CONFIG_PORT or KBCT_EXCHANGES_KRAKEN_APISECRET. The
+// File variants of secrets use a _FILE suffix instead, as in
+// KBCT_EXCHANGES_KRAKEN_APISECRET_FILE.
+func bindConfigEnv() {
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+
+	// Covers keys that are in the config file but not in the schema, such
+	// as aliases and fiat rates
+	viper.AutomaticEnv()
+
+	// Keys missing from the config file have to be bound to be seen
+	bind := func(key string, env string) {
+		configEnvNames[key] = env
+		viper.BindEnv(key, env)
+	}
+	for key := range configSchema {
+		bind(key, envName(key))
+	}
+	for _, exchange := range registeredExchanges() {
+		section := "exchanges." + strings.ToLower(exchange.Key()) + "."
+		for key := range exchangeSchema {
+			bind(section+key, envName(section+key))
+		}
+	}
+	for _, key := range secretKeys() {
+		bind(key+"file", envName(key)+"_FILE")
+	}
+}
+
+// Name of the environment variable of a setting
+func envName(key string) string {
+	return envPrefix + "_" + strings.ToUpper(strings.Replace(key, ".", "_", -1))
+}
+
+// Returns the environment variable overriding a setting, if it is set
+func configEnvOverride(key string) (string, bool) {
+	name, ok := configEnvNames[key]
+	if !ok {
+		name = envName(key)
+	}
+	if _, set := os.LookupEnv(name); !set {
+		return "", false
+	}
+	return name, true
+}
+
+// Reads a secret from its setting, or from the file named by its File
+// variant. Trailing new lines are dropped from files.
+func secretValue(key string) (string, error) {
+	value := viper.GetString(key)
+	file := viper.GetString(key + "file")
+	if len(file) == 0 {
+		return value, nil
+	}
+	if len(value) > 0 {
+		return "", errors.New("is set along with " + key + ", only one of them may be")
+	}
+
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(contents), "\r\n"), nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Printed in place of secrets
+const redacted = "<redacted>"
+
+// Printed in place of the password of a database connection string
+const redactedPassword = "xxxxx"
+
+// Password in a MySQL DSN such as user:password@tcp(localhost:3306)/tickers
+var dsnPassword = regexp.MustCompile(`^([^:@/]*):.*@`)
+
+// Password in a key/value DSN such as host=localhost password='s3 cret',
+// quoted or not
+var dsnPasswordField = regexp.MustCompile(`(^|\s)password\s*=\s*('(?:[^'\\]|\\.)*'|\S*)`)
+
+// Hides a secret, leaving empty values empty so they can be told apart
+func redact(secret string) string {
+	if len(secret) == 0 {
+		return ""
+	}
+	return redacted
+}
+
+// Hides the password of a database connection string
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && strings.Contains(dsn, "://") {
+		// The password can be in the user info or in the query
+		if u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				u.User = url.UserPassword(u.User.Username(), redactedPassword)
+			}
+		}
+		if query := u.Query(); len(query["password"]) > 0 {
+			query.Set("password", redactedPassword)
+			u.RawQuery = query.Encode()
+		}
+		return u.String()
+	}
+	if dsnPasswordField.MatchString(dsn) {
+		return dsnPasswordField.ReplaceAllString(dsn, "${1}password="+redactedPassword)
+	}
+	return dsnPassword.ReplaceAllString(dsn, "$1:"+redactedPassword+"@")
+}
+
+// Formats the config in effect, defaults and overrides included, as a
+// config file with its secrets hidden
+func redactedConfig(c Config) string {
+	var b strings.Builder
+	writeConfig(&b, c)
+	return b.String()
+}
+
+// Writes the config in effect as a config file with its secrets hidden
+func writeConfig(w io.Writer, c Config) {
+	str := strconv.Quote
+	dur := func(d time.Duration) string { return strconv.Quote(d.String()) }
+
+	fmt.Fprintln(w, "[config]")
+	fmt.Fprintf(w, "logFile = %s\n", str(c.LogFile))
+	fmt.Fprintf(w, "sqliteLocation = %s\n", str(c.SqliteLocation))
+	fmt.Fprintf(w, "port = %s\n", str(c.Port))
+	fmt.Fprintf(w, "workers = %d\n", c.Workers)
+	fmt.Fprintf(w, "fetchTimeout = %s\n", dur(c.FetchTimeout))
+	fmt.Fprintf(w, "interval = %s\n", dur(c.Interval))
+	fmt.Fprintf(w, "requestTimeout = %s\n", dur(c.RequestTimeout))
+	fmt.Fprintf(w, "retries = %d\n", c.Retries)
+	fmt.Fprintf(w, "retryBaseDelay = %s\n", dur(c.RetryBaseDelay))
+	fmt.Fprintf(w, "retryMaxDelay = %s\n", dur(c.RetryMaxDelay))
+	fmt.Fprintf(w, "breakerThreshold = %d\n", c.BreakerThreshold)
+	fmt.Fprintf(w, "breakerCooldown = %s\n", dur(c.BreakerCooldown))
+	fmt.Fprintf(w, "staleAfter = %g\n", c.StaleAfter)
+	fmt.Fprintf(w, "alertSecret = %s\n", str(redact(c.AlertSecret)))
+	fmt.Fprintf(w, "alertToken = %s\n", str(redact(c.AlertToken)))
+
+	fmt.Fprintln(w, "\n[database]")
+	fmt.Fprintf(w, "driver = %s\n", str(c.Database.Driver))
+	fmt.Fprintf(w, "dsn = %s\n", str(redactDSN(c.Database.DSN)))
+
+	fmt.Fprintln(w, "\n[aliases]")
+	for _, code := range sortedKeys(c.Aliases) {
+		fmt.Fprintf(w, "%s = %s\n", code, str(c.Aliases[code]))
+	}
+
+	fmt.Fprintln(w, "\n[analytics]")
+	fmt.Fprintf(w, "interval = %s\n", dur(c.Analytics.Interval))
+	fmt.Fprintf(w, "referenceExchange = %s\n", str(c.Analytics.ReferenceExchange))
+	fmt.Fprintf(w, "referencePair = %s\n", str(c.Analytics.ReferencePair))
+	fmt.Fprintf(w, "fiatRatesURL = %s\n", str(c.Analytics.FiatRatesURL))
+	fmt.Fprintf(w, "fiatBase = %s\n", str(c.Analytics.FiatBase))
+
+	fmt.Fprintln(w, "\n[analytics.fiatRates]")
+	var codes []string
+	for code := range c.Analytics.FiatRates {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "%s = %g\n", code, c.Analytics.FiatRates[code])
+	}
+
+	var keys []string
+	for key := range c.Exchanges {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		e := c.Exchanges[key]
+		fmt.Fprintf(w, "\n[exchanges.%s]\n", key)
+		fmt.Fprintf(w, "enabled = %t\n", e.Enabled)
+		fmt.Fprintf(w, "url = %s\n", str(e.URL))
+		fmt.Fprintf(w, "apiKey = %s\n", str(redact(e.APIKey)))
+		fmt.Fprintf(w, "apiSecret = %s\n", str(redact(e.APISecret)))
+		fmt.Fprintf(w, "tickers = %s\n", str(e.Tickers))
+		if e.Interval > 0 {
+			fmt.Fprintf(w, "interval = %s\n", dur(e.Interval))
+		}
+	}
+
+	for _, r := range c.Alerts {
+		fmt.Fprintln(w, "\n[[alerts]]")
+		fmt.Fprintf(w, "name = %s\n", str(r.Name))
+		fmt.Fprintf(w, "kind = %s\n", str(r.Kind))
+		fmt.Fprintf(w, "exchange = %s\n", str(r.Exchange))
+		fmt.Fprintf(w, "pair = %s\n", str(r.Pair))
+		fmt.Fprintf(w, "operator = %s\n", str(r.Operator))
+		fmt.Fprintf(w, "threshold = %g\n", r.Threshold)
+		if r.Window > 0 {
+			fmt.Fprintf(w, "window = %s\n", dur(time.Duration(r.Window)))
+		}
+		if len(r.IndexPair) > 0 {
+			fmt.Fprintf(w, "indexPair = %s\n", str(r.IndexPair))
+		}
+		fmt.Fprintf(w, "webhook = %s\n", str(r.Webhook))
+		fmt.Fprintf(w, "secret = %s\n", str(redact(r.Secret)))
+	}
+}
+
+// Returns the keys of a map in order
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
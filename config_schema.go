@@ -0,0 +1,575 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Kinds of values held by the config file
+type valueKind int
+
+const (
+	stringValue valueKind = iota
+	// http or https URL
+	urlValue
+	// TCP port, as a number or a string
+	portValue
+	// Positive duration such as "30s" or "10m"
+	durationValue
+	// Whole number of at least 0
+	countValue
+	// Whole number of at least 1
+	positiveCountValue
+	numberValue
+	// Number greater than 0
+	positiveNumberValue
+	boolValue
+	// Comma separated list of exchange symbols
+	tickersValue
+	// One of the storage backends
+	driverValue
+	// Registered exchange
+	exchangeValue
+	// Trading pair such as BTC-USD
+	pairValue
+)
+
+// Keys of the config file, lower cased as viper reports them. Exchange
+// sections, aliases, fiat rates and alert rules are checked separately.
+var configSchema = map[string]valueKind{
+	"config.logfile":          stringValue,
+	"config.sqlitelocation":   stringValue,
+	"config.port":             portValue,
+	"config.workers":          positiveCountValue,
+	"config.fetchtimeout":     durationValue,
+	"config.interval":         durationValue,
+	"config.requesttimeout":   durationValue,
+	"config.retries":          countValue,
+	"config.retrybasedelay":   durationValue,
+	"config.retrymaxdelay":    durationValue,
+	"config.breakerthreshold": positiveCountValue,
+	"config.breakercooldown":  durationValue,
+	"config.staleafter":       positiveNumberValue,
+	"config.alertsecret":      stringValue,
+	"config.alertsecretfile":  stringValue,
+	"config.alerttoken":       stringValue,
+	"config.alerttokenfile":   stringValue,
+
+	"database.driver":  driverValue,
+	"database.dsn":     stringValue,
+	"database.dsnfile": stringValue,
+
+	"analytics.interval":          durationValue,
+	"analytics.referenceexchange": exchangeValue,
+	"analytics.referencepair":     pairValue,
+	"analytics.fiatratesurl":      urlValue,
+	"analytics.fiatbase":          stringValue,
+}
+
+// Keys of every [exchanges.*] section
+var exchangeSchema = map[string]valueKind{
+	"enabled":       boolValue,
+	"url":           urlValue,
+	"apikey":        stringValue,
+	"apisecret":     stringValue,
+	"apikeyfile":    stringValue,
+	"apisecretfile": stringValue,
+	"tickers":       tickersValue,
+	"interval":      durationValue,
+}
+
+// Keys of every [[alerts]] table
+var alertSchema = map[string]valueKind{
+	"name":      stringValue,
+	"kind":      stringValue,
+	"exchange":  exchangeValue,
+	"pair":      pairValue,
+	"operator":  stringValue,
+	"threshold": numberValue,
+	"window":    durationValue,
+	"indexpair": pairValue,
+	"webhook":   urlValue,
+	"secret":    stringValue,
+}
+
+// A single exchange symbol in a tickers list
+var tickerPattern = regexp.MustCompile(`^[A-Za-z0-9_\-]{2,}$`)
+
+// Checks the config file read by viper against the schema, returning
+// every problem found along with its line in the file where known
+func checkConfigFile() []string {
+	lines, names := configKeyLines(viper.ConfigFileUsed())
+	c := &configChecker{lines: lines, names: names}
+
+	for key, value := range viper.AllSettings() {
+		c.section(strings.ToLower(key), value)
+	}
+	c.crossCheck()
+
+	return c.report(viper.ConfigFileUsed())
+}
+
+// A problem with the config file
+type configProblem struct {
+	key     string
+	message string
+}
+
+// Collects the problems with the config file
+type configChecker struct {
+	lines    map[string]int
+	names    map[string]string
+	problems []configProblem
+}
+
+func (c *configChecker) add(key string, format string, a ...interface{}) {
+	c.problems = append(c.problems, configProblem{key: key, message: fmt.Sprintf(format, a...)})
+}
+
+// Checks a top level section of the config file
+func (c *configChecker) section(name string, value interface{}) {
+	switch name {
+	case "config", "database", "analytics", "aliases", "exchanges", "alerts":
+	default:
+		c.add(name, "unknown section")
+		return
+	}
+
+	switch name {
+	case "exchanges":
+		c.exchanges(value)
+	case "aliases":
+		c.table(name, value, func(key string, value interface{}) {
+			c.value(key, value, stringValue)
+		})
+	case "alerts":
+		c.alerts(value)
+	default:
+		c.table(name, value, func(key string, value interface{}) {
+			if key == "analytics.fiatrates" {
+				c.table(key, value, func(key string, value interface{}) {
+					c.value(key, value, positiveNumberValue)
+				})
+				return
+			}
+
+			kind, ok := configSchema[key]
+			if !ok {
+				c.add(key, "unknown key")
+				return
+			}
+			c.value(key, value, kind)
+		})
+	}
+}
+
+// Calls fn with every key of a table, reporting values that aren't tables
+func (c *configChecker) table(name string, value interface{}, fn func(key string, value interface{})) {
+	entries, ok := value.(map[string]interface{})
+	if !ok {
+		c.add(name, "expected a [%s] table", name)
+		return
+	}
+	for key, value := range entries {
+		fn(name+"."+strings.ToLower(key), value)
+	}
+}
+
+// Checks the [exchanges.*] sections
+func (c *configChecker) exchanges(value interface{}) {
+	c.table("exchanges", value, func(section string, value interface{}) {
+		key := strings.TrimPrefix(section, "exchanges.")
+
+		var found bool
+		for _, exchange := range registeredExchanges() {
+			found = found || strings.ToLower(exchange.Key()) == key
+		}
+		if !found {
+			c.add(section, "unknown exchange, expected one of %s", exchangeKeys())
+			return
+		}
+
+		c.table(section, value, func(key string, value interface{}) {
+			kind, ok := exchangeSchema[strings.TrimPrefix(key, section+".")]
+			if !ok {
+				c.add(key, "unknown key")
+				return
+			}
+			c.value(key, value, kind)
+		})
+	})
+}
+
+// Checks the [[alerts]] tables, both their keys and the rules they make
+func (c *configChecker) alerts(value interface{}) {
+	var tables []map[string]interface{}
+	switch v := value.(type) {
+	case []map[string]interface{}:
+		tables = v
+	case []interface{}:
+		for _, table := range v {
+			t, ok := table.(map[string]interface{})
+			if !ok {
+				c.add("alerts", "expected [[alerts]] tables")
+				return
+			}
+			tables = append(tables, t)
+		}
+	default:
+		c.add("alerts", "expected [[alerts]] tables")
+		return
+	}
+
+	names := make(map[string]bool)
+	for i, table := range tables {
+		prefix := "alerts[" + strconv.Itoa(i) + "]"
+
+		valid := true
+		fields := make(map[string]interface{})
+		for key, value := range table {
+			key = strings.ToLower(key)
+			kind, ok := alertSchema[key]
+			if !ok {
+				c.add(prefix+"."+key, "unknown key")
+				valid = false
+				continue
+			}
+			if !c.value(prefix+"."+key, value, kind) {
+				valid = false
+			}
+			fields[key] = value
+		}
+		if !valid {
+			continue
+		}
+
+		// Check the rule as a whole as the API would
+		rule := &AlertRule{}
+		rule.Name, _ = fields["name"].(string)
+		rule.Kind, _ = fields["kind"].(string)
+		rule.Exchange, _ = fields["exchange"].(string)
+		rule.Pair, _ = fields["pair"].(string)
+		rule.Operator, _ = fields["operator"].(string)
+		rule.Threshold, _ = toFloat(fields["threshold"])
+		rule.IndexPair, _ = fields["indexpair"].(string)
+		rule.Webhook, _ = fields["webhook"].(string)
+		if window, ok := fields["window"].(string); ok && len(window) > 0 {
+			d, _ := time.ParseDuration(window)
+			rule.Window = jsonDuration(d)
+		}
+		if err := validateAlertRule(rule); err != nil {
+			c.add(prefix, "%s", err.Error())
+			continue
+		}
+
+		if names[rule.Name] {
+			c.add(prefix+".name", "%q is used by another rule", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+}
+
+// Checks a single value, returning whether it is valid
+func (c *configChecker) value(key string, value interface{}, kind valueKind) bool {
+	problem := checkValue(value, kind)
+	if len(problem) > 0 {
+		c.add(key, "%s", problem)
+		return false
+	}
+	return true
+}
+
+// Checks the settings that depend on each other
+func (c *configChecker) crossCheck() {
+	driver := strings.ToLower(viper.GetString("database.driver"))
+	if (driver == "postgres" || driver == "mysql") && len(viper.GetString("database.dsn")) == 0 {
+		c.add("database.dsn", "is required for %s", driver)
+	}
+
+	for _, key := range secretKeys() {
+		if _, err := secretValue(key); err != nil {
+			c.add(key+"file", "%s", err.Error())
+		}
+	}
+
+	// An empty or half written file would otherwise pass as all defaults
+	if !viper.InConfig("config") {
+		c.add("config", "missing section")
+	}
+
+	// Exchanges that are polled need somewhere to poll
+	for _, exchange := range registeredExchanges() {
+		if _, ok := exchange.(clientExchange); ok {
+			continue
+		}
+		section := "exchanges." + exchange.Key() + "."
+		if viper.IsSet(section+"enabled") && !viper.GetBool(section+"enabled") {
+			continue
+		}
+		if len(viper.GetString(section+"url")) == 0 {
+			c.add(section+"url", "is required unless enabled = false")
+		}
+	}
+
+	base, max := viper.GetDuration("config.retryBaseDelay"), viper.GetDuration("config.retryMaxDelay")
+	if base > 0 && max > 0 && base > max {
+		c.add("config.retrybasedelay", "is longer than retryMaxDelay")
+	}
+}
+
+// Returns what is wrong with a value, or an empty string. Empty strings
+// are always valid and leave the setting at its default.
+func checkValue(value interface{}, kind valueKind) string {
+	if s, ok := value.(string); ok && len(s) == 0 && kind != boolValue {
+		return ""
+	}
+
+	switch kind {
+	case stringValue:
+		if _, ok := value.(string); !ok {
+			return "expected a string"
+		}
+
+	case urlValue:
+		s, ok := value.(string)
+		if !ok {
+			return "expected a URL"
+		}
+		u, err := url.Parse(s)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			return fmt.Sprintf("%q is not an http or https URL", s)
+		}
+
+	case portValue:
+		port, ok := toInt(value)
+		if !ok || port < 1 || port > 65535 {
+			return fmt.Sprintf("%v is not a port number", value)
+		}
+
+	case durationValue:
+		s, ok := value.(string)
+		if !ok {
+			return `expected a duration such as "30s" or "10m"`
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return fmt.Sprintf(`%q is not a positive duration such as "30s" or "10m"`, s)
+		}
+
+	case countValue, positiveCountValue:
+		n, ok := toInt(value)
+		if !ok {
+			return "expected a whole number"
+		}
+		if kind == positiveCountValue && n < 1 {
+			return "must be at least 1"
+		}
+		if n < 0 {
+			return "must be at least 0"
+		}
+
+	case numberValue, positiveNumberValue:
+		f, ok := toFloat(value)
+		if !ok {
+			return "expected a number"
+		}
+		if kind == positiveNumberValue && f <= 0 {
+			return "must be greater than 0"
+		}
+
+	case boolValue:
+		if s, ok := value.(string); ok {
+			if _, err := strconv.ParseBool(s); err == nil {
+				return ""
+			}
+		}
+		if _, ok := value.(bool); !ok {
+			return "expected true or false"
+		}
+
+	case tickersValue:
+		s, ok := value.(string)
+		if !ok {
+			return "expected a comma separated list of tickers"
+		}
+		for _, ticker := range strings.Split(s, ",") {
+			ticker = strings.TrimSpace(ticker)
+			if len(ticker) > 0 && !tickerPattern.MatchString(ticker) {
+				return fmt.Sprintf("%q is not a ticker", ticker)
+			}
+		}
+
+	case driverValue:
+		s, _ := value.(string)
+		switch strings.ToLower(s) {
+		case "sqlite", "sqlite3", "postgres", "postgresql", "mysql", "mariadb":
+		default:
+			return fmt.Sprintf(`%v is not one of "sqlite", "postgres" or "mysql"`, value)
+		}
+
+	case exchangeValue:
+		s, ok := value.(string)
+		if !ok {
+			return "expected an exchange"
+		}
+		if _, err := lookupExchange(s); err != nil {
+			return fmt.Sprintf("%q is not one of %s", s, exchangeKeys())
+		}
+
+	case pairValue:
+		s, ok := value.(string)
+		if !ok {
+			return "expected a pair such as BTC-USD"
+		}
+		if _, err := parsePair(s); err != nil {
+			return fmt.Sprintf("%q is not a pair such as BTC-USD", s)
+		}
+	}
+
+	return ""
+}
+
+// Reads a whole number decoded from TOML, or given as a string by an
+// environment variable
+func toInt(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// Reads a number decoded from TOML, or given as a string by an
+// environment variable
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	n, ok := toInt(value)
+	return float64(n), ok
+}
+
+// Lists the keys of the registered exchanges
+func exchangeKeys() string {
+	var keys []string
+	for _, exchange := range registeredExchanges() {
+		keys = append(keys, exchange.Key())
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
+
+// Formats the problems as file:line: key: message, sorted by line
+func (c *configChecker) report(file string) []string {
+	sort.SliceStable(c.problems, func(i, j int) bool {
+		li, lj := c.line(c.problems[i].key), c.line(c.problems[j].key)
+		if li != lj {
+			return li < lj
+		}
+		return c.problems[i].key < c.problems[j].key
+	})
+
+	var resp []string
+	for _, p := range c.problems {
+		// Name the key as it is spelled in the file
+		key := p.key
+		if name, ok := c.names[key]; ok {
+			key = name
+		}
+
+		if env, ok := configEnvOverride(p.key); ok {
+			resp = append(resp, fmt.Sprintf("%s: %s: %s", env, key, p.message))
+		} else if line := c.line(p.key); line > 0 {
+			resp = append(resp, fmt.Sprintf("%s:%d: %s: %s", file, line, key, p.message))
+		} else {
+			resp = append(resp, fmt.Sprintf("%s: %s: %s", file, key, p.message))
+		}
+	}
+	return resp
+}
+
+// Finds the line of a key, or of the closest table holding it
+func (c *configChecker) line(key string) int {
+	for len(key) > 0 {
+		if line, ok := c.lines[key]; ok {
+			return line
+		}
+		i := strings.LastIndex(key, ".")
+		if i < 0 {
+			break
+		}
+		key = key[:i]
+	}
+	return 0
+}
+
+// Maps every key and table of a TOML file onto its line and its spelling
+// in the file. Keys are lower cased and [[alerts]] tables are numbered as
+// alerts[0], alerts[1], ...
+func configKeyLines(file string) (map[string]int, map[string]string) {
+	lines := make(map[string]int)
+	names := make(map[string]string)
+
+	f, err := os.Open(file)
+	if err != nil {
+		return lines, names
+	}
+	defer f.Close()
+
+	var (
+		table  string
+		name   string
+		arrays = make(map[string]int)
+		n      int
+	)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case len(line) == 0 || strings.HasPrefix(line, "#"):
+			continue
+
+		case strings.HasPrefix(line, "[["):
+			array := strings.Trim(strings.SplitN(line, "]]", 2)[0], "[ ")
+			index := "[" + strconv.Itoa(arrays[strings.ToLower(array)]) + "]"
+			arrays[strings.ToLower(array)]++
+			table, name = strings.ToLower(array)+index, array+index
+			lines[table], names[table] = n, name
+
+		case strings.HasPrefix(line, "["):
+			name = strings.Trim(strings.SplitN(line, "]", 2)[0], "[ ")
+			table = strings.ToLower(name)
+			lines[table], names[table] = n, name
+
+		case strings.Contains(line, "="):
+			key := strings.Trim(strings.TrimSpace(strings.SplitN(line, "=", 2)[0]), `"'`)
+			if len(table) > 0 {
+				key = name + "." + key
+			}
+			if _, ok := lines[strings.ToLower(key)]; !ok {
+				lines[strings.ToLower(key)], names[strings.ToLower(key)] = n, key
+			}
+		}
+	}
+	return lines, names
+}
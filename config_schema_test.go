@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// A config file that passes the checks, polling every exchange that needs
+// a url
+const testConfigFile = `[config]
+logFile = "/dev/null"
+port = "9091"
+interval = "10m"
+
+[exchanges.luno]
+url = "https://api.mybitx.com/api/1/tickers"
+
+[exchanges.bitstamp]
+url = "https://www.bitstamp.net/api/v2/ticker_hour/btcusd/"
+
+[exchanges.bitfinex]
+url = "https://api.bitfinex.com/v1/pubticker/"
+tickers = "btcusd"
+
+[exchanges.bitsquare]
+url = "https://market.bisq.io/api/ticker?market="
+tickers = "btc_eur"
+
+[exchanges.btcc]
+url = "https://spotusd-data.btcc.com/data/pro/ticker?symbol="
+tickers = "btcusd"
+
+[exchanges.okcoin]
+url = "https://www.okcoin.com/api/v1/ticker.do?symbol="
+tickers = "btc_usd"
+`
+
+// Writes a config file and points viper at it, returning its path
+func writeTestConfig(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Reset()
+	viper.SetConfigFile(path)
+	return path
+}
+
+// Reads a config file the way configInit does and checks it
+func checkTestConfig(t *testing.T, contents string) []string {
+	t.Helper()
+
+	writeTestConfig(t, contents)
+	if _, err := readConfigFile(); err != nil {
+		return []string{err.Error()}
+	}
+	return checkConfigFile()
+}
+
+func TestCheckConfigFile(t *testing.T) {
+	cases := []struct {
+		name     string
+		contents string
+		// Expected in one of the problems, or no problems if empty
+		problem string
+	}{
+		{"valid", testConfigFile, ""},
+		{"empty", "", "config: missing section"},
+		{"truncated", "[config]\nport = \"9091\"\n", "exchanges.luno.url: is required unless enabled = false"},
+		{"unknown key", strings.Replace(testConfigFile, `port = "9091"`, `prot = "9091"`, 1), ":3: config.prot: unknown key"},
+		{"unknown section", testConfigFile + "[extra]\nkey = 1\n", "extra: unknown section"},
+		{"bad duration", strings.Replace(testConfigFile, `interval = "10m"`, `interval = "ten minutes"`, 1), `:4: config.interval: "ten minutes" is not a positive duration`},
+		{"disabled without url", strings.Replace(testConfigFile, "url = \"https://api.mybitx.com/api/1/tickers\"", "enabled = false", 1), ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			problems := checkTestConfig(t, c.contents)
+
+			if len(c.problem) == 0 {
+				if len(problems) > 0 {
+					t.Fatalf("expected no problems, got %q", problems)
+				}
+				return
+			}
+			for _, problem := range problems {
+				if strings.Contains(problem, c.problem) {
+					return
+				}
+			}
+			t.Fatalf("expected a problem containing %q, got %q", c.problem, problems)
+		})
+	}
+}
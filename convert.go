@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Most legs a conversion may take
+const maxConversionLegs = 4
+
+// One direction of a stored pair. Every pair gives two edges, base to
+// quote at the mid price and quote to base at its inverse.
+type conversionEdge struct {
+	from     string
+	to       string
+	rate     float64
+	inverted bool
+	quote    *APIStruct
+}
+
+// Conversion graph keyed by the asset being converted from
+type conversionGraph map[string][]*conversionEdge
+
+// Builds a conversion graph from the latest quotes, leaving out quotes
+// that are stale or have no price. Edges are ordered freshest first, so
+// the freshest exchange wins where several list the same pair.
+func buildConversionGraph(quotes []*APIStruct, maxAge time.Duration, now time.Time) conversionGraph {
+	graph := make(conversionGraph)
+	for _, q := range quotes {
+		if len(q.Base) == 0 || len(q.Quote) == 0 || !isFinite(q.Average) || q.Average <= 0 {
+			continue
+		}
+		if now.Sub(time.Unix(int64(q.Timestamp), 0)) > quoteMaxAge(q.Exchange, maxAge) {
+			continue
+		}
+
+		graph[q.Base] = append(graph[q.Base], &conversionEdge{from: q.Base, to: q.Quote, rate: q.Average, quote: q})
+		graph[q.Quote] = append(graph[q.Quote], &conversionEdge{from: q.Quote, to: q.Base, rate: 1 / q.Average, inverted: true, quote: q})
+	}
+
+	for asset := range graph {
+		edges := graph[asset]
+		sort.SliceStable(edges, func(i, j int) bool { return edges[i].quote.Timestamp > edges[j].quote.Timestamp })
+	}
+	return graph
+}
+
+// Finds the path with the fewest legs from one asset to another using a
+// breadth first search. Returns nil if there is no such path.
+func (g conversionGraph) path(from string, to string) []*conversionEdge {
+	if from == to {
+		return []*conversionEdge{}
+	}
+
+	// How each asset was first reached
+	via := map[string]*conversionEdge{from: nil}
+	queue := []string{from}
+	depth := map[string]int{from: 0}
+
+	for len(queue) > 0 {
+		asset := queue[0]
+		queue = queue[1:]
+
+		if depth[asset] >= maxConversionLegs {
+			continue
+		}
+
+		for _, edge := range g[asset] {
+			if _, seen := via[edge.to]; seen {
+				continue
+			}
+			via[edge.to] = edge
+			depth[edge.to] = depth[asset] + 1
+
+			// Walk back to the start to get the path
+			if edge.to == to {
+				var path []*conversionEdge
+				for e := edge; e != nil; e = via[e.from] {
+					path = append([]*conversionEdge{e}, path...)
+				}
+				return path
+			}
+			queue = append(queue, edge.to)
+		}
+	}
+	return nil
+}
+
+// Converts an amount along the shortest path between two assets
+func convertAmount(graph conversionGraph, from string, to string, amount float64, now time.Time) (*Conversion, error) {
+	path := graph.path(from, to)
+	if path == nil {
+		return nil, errors.New("No conversion path from " + from + " to " + to)
+	}
+
+	resp := &Conversion{
+		From:   from,
+		To:     to,
+		Amount: amount,
+		Rate:   1,
+		Path:   []*ConversionLeg{},
+	}
+	for _, edge := range path {
+		resp.Rate *= edge.rate
+		resp.Path = append(resp.Path, &ConversionLeg{
+			From:        edge.from,
+			To:          edge.to,
+			Exchange:    edge.quote.Exchange,
+			Pair:        edge.quote.Base + "-" + edge.quote.Quote,
+			Rate:        edge.rate,
+			Inverted:    edge.inverted,
+			DateUpdated: edge.quote.DateUpdated,
+			Age:         int64(now.Sub(time.Unix(int64(edge.quote.Timestamp), 0)) / time.Second),
+		})
+	}
+	resp.Result = math.Round(amount*resp.Rate*1e8) / 1e8
+
+	return resp, nil
+}
+
+// Convert an amount between two assets, going through intermediate pairs
+// when no exchange lists the pair directly
+func getConversion(w http.ResponseWriter, req *http.Request) {
+
+	var (
+		query = req.URL.Query()
+		from  = canonicalAsset(strings.TrimSpace(query.Get("from")))
+		to    = canonicalAsset(strings.TrimSpace(query.Get("to")))
+	)
+
+	if len(from) == 0 || len(to) == 0 {
+		http.Error(w, "from and to are required", http.StatusBadRequest)
+		return
+	}
+
+	// Default to converting a single unit
+	amount := 1.0
+	if value := query.Get("amount"); len(value) > 0 {
+		var err error
+		amount, err = strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) || amount <= 0 {
+			http.Error(w, "amount must be a positive number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// A fixed maximum age, rather than one based on each exchange's interval
+	var maxAge time.Duration
+	if value := query.Get("maxAge"); len(value) > 0 {
+		var err error
+		maxAge, err = time.ParseDuration(value)
+		if err != nil || maxAge <= 0 {
+			http.Error(w, "maxAge must be a positive duration, eg. 15m", http.StatusBadRequest)
+			return
+		}
+	}
+
+	quotes, err := store.LatestQuotes(Pair{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	data, err := convertAmount(buildConversionGraph(quotes, maxAge, now), from, to, amount, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	log.Infof("Called: convert %s -> %s\n", from, to)
+
+	json.NewEncoder(w).Encode(data)
+}
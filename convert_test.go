@@ -0,0 +1,109 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConvertAmount(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	quote := func(exchange string, base string, quote string, price float64, age time.Duration) *APIStruct {
+		return &APIStruct{
+			Exchange:  exchange,
+			Base:      base,
+			Quote:     quote,
+			Average:   price,
+			Timestamp: float64(now.Add(-age).Unix()),
+		}
+	}
+
+	quotes := []*APIStruct{
+		quote("Luno", "BTC", "ZAR", 50000, 0),
+		quote("Bitstamp", "BTC", "USD", 4000, time.Minute),
+		quote("Bitstamp", "ETH", "BTC", 0.05, 0),
+		quote("Kraken", "BTC", "USD", 4100, 0),
+		quote("Bitfinex", "LTC", "USD", 50, time.Hour),
+		quote("OKCoin", "XRP", "USD", math.NaN(), 0),
+	}
+	graph := buildConversionGraph(quotes, 30*time.Minute, now)
+
+	cases := []struct {
+		name   string
+		from   string
+		to     string
+		amount float64
+		result float64
+		legs   int
+		err    bool
+	}{
+		{"direct", "BTC", "ZAR", 2, 100000, 1, false},
+		{"inverted", "ZAR", "BTC", 100000, 2, 1, false},
+		{"freshest exchange", "BTC", "USD", 1, 4100, 1, false},
+		{"two legs", "ETH", "ZAR", 1, 2500, 2, false},
+		{"two legs inverted", "USD", "ETH", 205, 1, 2, false},
+		{"same asset", "BTC", "BTC", 3, 3, 0, false},
+		{"zero amount", "BTC", "ZAR", 0, 0, 1, false},
+		{"stale pair", "LTC", "USD", 1, 0, 0, true},
+		{"no price", "XRP", "USD", 1, 0, 0, true},
+		{"unknown asset", "DOGE", "BTC", 1, 0, 0, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			conversion, err := convertAmount(graph, c.from, c.to, c.amount, now)
+			if c.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", conversion)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if conversion.Result != c.result {
+				t.Errorf("expected %g, got %g", c.result, conversion.Result)
+			}
+			if len(conversion.Path) != c.legs {
+				t.Errorf("expected %d legs, got %d", c.legs, len(conversion.Path))
+			}
+		})
+	}
+}
+
+func TestConversionPathLimit(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+
+	// A chain of pairs, one leg longer than allowed
+	var quotes []*APIStruct
+	assets := []string{"A", "B", "C", "D", "E", "F"}
+	for i := 1; i < len(assets); i++ {
+		quotes = append(quotes, &APIStruct{Exchange: "Luno", Base: assets[i-1], Quote: assets[i], Average: 2, Timestamp: float64(now.Unix())})
+	}
+	graph := buildConversionGraph(quotes, time.Minute, now)
+
+	if path := graph.path("A", "E"); len(path) != maxConversionLegs {
+		t.Errorf("expected a path of %d legs, got %d", maxConversionLegs, len(path))
+	}
+	if path := graph.path("A", "F"); path != nil {
+		t.Errorf("expected no path over %d legs, got %d legs", maxConversionLegs, len(path))
+	}
+}
+
+func TestGetConversionRejectsAmount(t *testing.T) {
+	cases := []string{"0", "-1", "NaN", "Inf", "one"}
+
+	for _, amount := range cases {
+		t.Run(amount, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			getConversion(w, httptest.NewRequest("GET", "/convert?from=BTC&to=ZAR&amount="+amount, nil))
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Exchange is implemented by every exchange adapter. Adding a new exchange
+// means writing one of these and registering it from an init function.
+type Exchange interface {
+	// Key is the name of the [exchanges.*] section in config.toml
+	Key() string
+	// Name is the exchange name as it is stored in the database
+	Name() string
+	// Pairs returns the configured tickers, or nil if all are fetched
+	Pairs() []string
+	// ParseSymbol maps an exchange-native symbol onto a pair
+	ParseSymbol(symbol string) (Pair, error)
+	// Fetch grabs a snapshot of the exchange as normalized quotes,
+	// giving up once ctx is done
+	Fetch(ctx context.Context) ([]Quote, error)
+}
+
+// Implemented by exchanges queried through an API client library rather
+// than their url setting, which they don't need
+type clientExchange interface {
+	Exchange
+	usesAPIClient()
+}
+
+// Quote is a single normalized ticker row ready to be stored. Volume is
+// the amount of the base currency traded over the last 24 hours, so that
+// volumes can be compared across exchanges.
+type Quote struct {
+	Exchange     string
+	Timestamp    string
+	Ask          string
+	Bid          string
+	Volume       string
+	CurrencyCode string
+	Pair         Pair
+}
+
+// Maps a symbol onto its pair using the exchange's mapper. Symbols that
+// can't be mapped are logged and stored without a pair.
+func symbolPair(e Exchange, symbol string) Pair {
+	pair, err := e.ParseSymbol(symbol)
+	if err != nil {
+		log.Warningf("%s: %s", e.Name(), err.Error())
+	}
+	return pair
+}
+
+// Registered exchanges, in the order in which they were registered
+var exchangeRegistry []Exchange
+
+// Adds an exchange to the registry
+func registerExchange(e Exchange) {
+	for i := range exchangeRegistry {
+		if exchangeRegistry[i].Key() == e.Key() {
+			log.Warningf("Exchange %s registered twice", e.Key())
+			return
+		}
+	}
+	exchangeRegistry = append(exchangeRegistry, e)
+}
+
+// Returns all the registered exchanges
+func registeredExchanges() []Exchange {
+	return exchangeRegistry
+}
+
+// Finds a registered exchange by its config key or stored name
+func lookupExchange(name string) (Exchange, error) {
+	for i := range exchangeRegistry {
+		if strings.EqualFold(exchangeRegistry[i].Key(), name) || strings.EqualFold(exchangeRegistry[i].Name(), name) {
+			return exchangeRegistry[i], nil
+		}
+	}
+	return nil, errors.New("Exchange doesn't exist")
+}
+
+// Returns the config section for an exchange
+func exchangeConfig(e Exchange) ExchangeConfig {
+	return config.Exchanges[e.Key()]
+}
+
+// Splits a comma separated list of tickers from the config file,
+// skipping anything that is too short to be a ticker
+func splitTickers(tickers string) []string {
+	var resp []string
+	for _, ticker := range strings.Split(tickers, ",") {
+		// Check if there is any data in the string
+		// if not, skip it
+		if len(ticker) < 2 {
+			continue
+		}
+		resp = append(resp, ticker)
+	}
+	return resp
+}
+
+// Performs an API call to a URL and decodes the JSON body into record
+func fetchJSON(ctx context.Context, url string, record interface{}) error {
+	// Make the API call
+	resp := apiCall(ctx, url)
+
+	// If an empty response was returned
+	if resp == nil {
+		return errors.New("Empty response from " + url)
+	}
+
+	// Callers should close resp.Body
+	// when done reading from it
+	defer resp.Body.Close()
+
+	// Use json.Decode for reading streams of JSON data
+	return json.NewDecoder(resp.Body).Decode(record)
+}
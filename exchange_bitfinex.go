@@ -0,0 +1,61 @@
+package main
+
+import "context"
+
+func init() {
+	registerExchange(&bitfinexExchange{})
+}
+
+type Bitfinex struct {
+	Ask       string `json:"ask"`
+	Bid       string `json:"bid"`
+	High      string `json:"high"`
+	LastPrice string `json:"last_price"`
+	Low       string `json:"low"`
+	Mid       string `json:"mid"`
+	Timestamp string `json:"timestamp"`
+	Volume    string `json:"volume"`
+}
+
+// Bitfinex is queried once per ticker set in the config file
+type bitfinexExchange struct{}
+
+func (b *bitfinexExchange) Key() string     { return "bitfinex" }
+func (b *bitfinexExchange) Name() string    { return "Bitfinex" }
+func (b *bitfinexExchange) Pairs() []string { return splitTickers(exchangeConfig(b).Tickers) }
+
+// Bitfinex symbols are two three letter codes, eg. ethbtc
+func (b *bitfinexExchange) ParseSymbol(symbol string) (Pair, error) {
+	return splitFixedSymbol(symbol)
+}
+
+// Grabs a snapshot of the current bitfinex exchange
+func (b *bitfinexExchange) Fetch(ctx context.Context) ([]Quote, error) {
+	var quotes []Quote
+	var lastErr error
+	for _, ticker := range b.Pairs() {
+		// Fill the record with the data from the JSON
+		var record Bitfinex
+		if err := fetchJSON(ctx, exchangeConfig(b).URL+ticker, &record); err != nil {
+			log.Error(err.Error())
+			lastErr = err
+			continue
+		}
+
+		quotes = append(quotes, Quote{
+			Exchange:     b.Name(),
+			Timestamp:    record.Timestamp,
+			Ask:          record.Ask,
+			Bid:          record.Bid,
+			Volume:       record.Volume,
+			CurrencyCode: formatCurrencyString(ticker, "Bitfinex"),
+			Pair:         symbolPair(b, ticker),
+		})
+	}
+
+	// Only count it as a failure if no ticker could be fetched
+	if len(quotes) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return quotes, nil
+}
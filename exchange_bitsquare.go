@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+func init() {
+	registerExchange(&bitsquareExchange{})
+}
+
+type Bitsquare struct {
+	Buy         string `json:"buy"`
+	High        string `json:"high"`
+	Last        string `json:"last"`
+	Low         string `json:"low"`
+	Sell        string `json:"sell"`
+	VolumeLeft  string `json:"volume_left"`
+	VolumeRight string `json:"volume_right"`
+}
+
+// Bitsquare is queried once per ticker set in the config file
+type bitsquareExchange struct{}
+
+func (b *bitsquareExchange) Key() string     { return "bitsquare" }
+func (b *bitsquareExchange) Name() string    { return "Bitsquare" }
+func (b *bitsquareExchange) Pairs() []string { return splitTickers(exchangeConfig(b).Tickers) }
+
+// Bitsquare markets are separated by an underscore, eg. btc_eur
+func (b *bitsquareExchange) ParseSymbol(symbol string) (Pair, error) {
+	return splitSeparatedSymbol(symbol, "_")
+}
+
+// Grabs a snapshot of the current bitsquare exchange
+func (b *bitsquareExchange) Fetch(ctx context.Context) ([]Quote, error) {
+	var quotes []Quote
+	var lastErr error
+	for _, ticker := range b.Pairs() {
+		// Fill the record with the data from the JSON
+		var record []Bitsquare
+		if err := fetchJSON(ctx, exchangeConfig(b).URL+ticker, &record); err != nil {
+			log.Error(err.Error())
+			lastErr = err
+			continue
+		}
+
+		// Bitsquare returns an empty list for unknown markets
+		if len(record) == 0 {
+			continue
+		}
+
+		quotes = append(quotes, Quote{
+			Exchange:     b.Name(),
+			Timestamp:    strconv.FormatInt(int64(time.Now().Unix()), 10),
+			Ask:          record[0].Sell,
+			Bid:          record[0].Buy,
+			Volume:       record[0].VolumeLeft,
+			CurrencyCode: formatCurrencyString(ticker, "Bitsquare"),
+			Pair:         symbolPair(b, ticker),
+		})
+	}
+
+	// Only count it as a failure if no ticker could be fetched
+	if len(quotes) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return quotes, nil
+}
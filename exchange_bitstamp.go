@@ -0,0 +1,50 @@
+package main
+
+import "context"
+
+func init() {
+	registerExchange(&bitstampExchange{})
+}
+
+type Bitstamp struct {
+	High      string `json:"high"`
+	Last      string `json:"last"`
+	Timestamp string `json:"timestamp"`
+	Bid       string `json:"bid"`
+	Vwap      string `json:"vwap"`
+	Volume    string `json:"volume"`
+	Low       string `json:"low"`
+	Ask       string `json:"ask"`
+	Open      string `json:"open"`
+}
+
+// Bitstamp is queried for USD only
+type bitstampExchange struct{}
+
+func (b *bitstampExchange) Key() string     { return "bitstamp" }
+func (b *bitstampExchange) Name() string    { return "Bitstamp" }
+func (b *bitstampExchange) Pairs() []string { return []string{"btcusd"} }
+
+// Bitstamp symbols are two three letter codes, eg. btcusd
+func (b *bitstampExchange) ParseSymbol(symbol string) (Pair, error) {
+	return splitFixedSymbol(symbol)
+}
+
+// Grabs a snapshot of the current bitstamp exchange
+func (b *bitstampExchange) Fetch(ctx context.Context) ([]Quote, error) {
+	// Fill the record with the data from the JSON
+	var record Bitstamp
+	if err := fetchJSON(ctx, exchangeConfig(b).URL, &record); err != nil {
+		return nil, err
+	}
+
+	return []Quote{{
+		Exchange:     b.Name(),
+		Timestamp:    record.Timestamp,
+		Ask:          record.Ask,
+		Bid:          record.Bid,
+		Volume:       record.Volume,
+		CurrencyCode: "USD",
+		Pair:         symbolPair(b, "btcusd"),
+	}}, nil
+}
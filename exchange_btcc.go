@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"strconv"
+)
+
+func init() {
+	registerExchange(&btccExchange{})
+}
+
+type BTCC struct {
+	Ticker struct {
+		AskPrice           float64 `json:"AskPrice"`
+		BidPrice           float64 `json:"BidPrice"`
+		ExecutionLimitDown float64 `json:"ExecutionLimitDown"`
+		ExecutionLimitUp   float64 `json:"ExecutionLimitUp"`
+		High               float64 `json:"High"`
+		Last               float64 `json:"Last"`
+		LastQuantity       float64 `json:"LastQuantity"`
+		Low                float64 `json:"Low"`
+		Open               float64 `json:"Open"`
+		PrevCls            float64 `json:"PrevCls"`
+		Timestamp          int64   `json:"Timestamp"`
+		Volume             float64 `json:"Volume"`
+		Volume24H          float64 `json:"Volume24H"`
+	} `json:"ticker"`
+}
+
+// BTCC is queried once per ticker set in the config file
+type btccExchange struct{}
+
+func (b *btccExchange) Key() string     { return "btcc" }
+func (b *btccExchange) Name() string    { return "BTCChina" }
+func (b *btccExchange) Pairs() []string { return splitTickers(exchangeConfig(b).Tickers) }
+
+// BTCC symbols are two three letter codes, eg. btcusd
+func (b *btccExchange) ParseSymbol(symbol string) (Pair, error) {
+	return splitFixedSymbol(symbol)
+}
+
+// Grabs a snapshot of the current BTCC exchange
+func (b *btccExchange) Fetch(ctx context.Context) ([]Quote, error) {
+	var quotes []Quote
+	var lastErr error
+	for _, ticker := range b.Pairs() {
+		// Fill the record with the data from the JSON
+		var record BTCC
+		if err := fetchJSON(ctx, exchangeConfig(b).URL+ticker, &record); err != nil {
+			log.Error(err.Error())
+			lastErr = err
+			continue
+		}
+
+		quotes = append(quotes, Quote{
+			Exchange:     b.Name(),
+			Timestamp:    strconv.FormatInt((record.Ticker.Timestamp / 1000), 10),
+			Ask:          strconv.FormatFloat(record.Ticker.AskPrice, 'f', 2, 64),
+			Bid:          strconv.FormatFloat(record.Ticker.BidPrice, 'f', 2, 64),
+			Volume:       strconv.FormatFloat(record.Ticker.Volume24H, 'f', 8, 64),
+			CurrencyCode: formatCurrencyString(ticker, "btcc"),
+			Pair:         symbolPair(b, ticker),
+		})
+	}
+
+	// Only count it as a failure if no ticker could be fetched
+	if len(quotes) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return quotes, nil
+}
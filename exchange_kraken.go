@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Beldur/kraken-go-api-client"
+)
+
+func init() {
+	registerExchange(&krakenExchange{})
+}
+
+// Kraken is queried through its API client and requires API keys
+type krakenExchange struct{}
+
+func (k *krakenExchange) Key() string    { return "kraken" }
+func (k *krakenExchange) Name() string   { return "Kraken" }
+func (k *krakenExchange) usesAPIClient() {}
+
+func (k *krakenExchange) Pairs() []string {
+	return []string{krakenapi.XXBTZEUR, krakenapi.XXBTZUSD, krakenapi.XXBTZGBP, krakenapi.DASHXBT, krakenapi.XETCXXBT, krakenapi.XLTCXXBT}
+}
+
+// Kraken names assets with four letters, eg. XXBTZEUR, except for newer
+// assets which are left bare, eg. DASHXBT. The names are mapped onto
+// common tickers by the aliases.
+func (k *krakenExchange) ParseSymbol(symbol string) (Pair, error) {
+	if len(symbol) == 8 {
+		return Pair{Base: canonicalAsset(symbol[:4]), Quote: canonicalAsset(symbol[4:])}, nil
+	}
+	if strings.HasSuffix(symbol, "XBT") && len(symbol) > 3 {
+		return Pair{Base: canonicalAsset(symbol[:len(symbol)-3]), Quote: canonicalAsset("XBT")}, nil
+	}
+	return Pair{}, errors.New("Unknown symbol " + symbol)
+}
+
+// Gets ticker data from kraken
+func (k *krakenExchange) Fetch(ctx context.Context) ([]Quote, error) {
+	cfg := exchangeConfig(k)
+
+	// If the API keys are not present, just return
+	if len(cfg.APIKey) == 0 && len(cfg.APISecret) == 0 {
+		return nil, nil
+	}
+
+	api := krakenapi.New(cfg.APIKey, cfg.APISecret)
+
+	// There are also some strongly typed methods available
+	var ticker *krakenapi.TickerResponse
+	err := withRetry(ctx, k.Name(), func() (err error) {
+		ticker, err = api.Ticker(k.Pairs()...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a timestamp now
+	ts := strconv.FormatInt(int64(time.Now().Unix()), 10)
+
+	var quotes []Quote
+	v := reflect.ValueOf(ticker).Elem()
+	typeOfT := v.Type()
+	for j := 0; j < v.NumField(); j++ {
+
+		f := v.Field(j)
+		inter := f.Interface().(krakenapi.PairTickerInfo)
+
+		// Check if the ask value is empty. The second volume is over the
+		// last 24 hours, the first only since midnight.
+		if len(inter.Ask) > 0 {
+			quotes = append(quotes, Quote{
+				Exchange:     k.Name(),
+				Timestamp:    ts,
+				Ask:          inter.Ask[0],
+				Bid:          inter.Bid[0],
+				Volume:       inter.Volume[1],
+				CurrencyCode: formatCurrencyString(typeOfT.Field(j).Name, "Kraken"),
+				Pair:         symbolPair(k, typeOfT.Field(j).Name),
+			})
+		}
+	}
+	return quotes, nil
+}
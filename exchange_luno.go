@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+func init() {
+	registerExchange(&lunoExchange{})
+}
+
+// Luno Ticker
+type LunoTicker struct {
+	Tickers []struct {
+		Timestamp           int64  `json:"timestamp"`
+		Bid                 string `json:"bid"`
+		Ask                 string `json:"ask"`
+		LastTrade           string `json:"last_trade"`
+		Rolling24HourVolume string `json:"rolling_24_hour_volume"`
+		Pair                string `json:"pair"`
+	} `json:"tickers"`
+}
+
+// Luno returns every ticker it supports in a single call
+type lunoExchange struct{}
+
+func (l *lunoExchange) Key() string     { return "luno" }
+func (l *lunoExchange) Name() string    { return "Luno" }
+func (l *lunoExchange) Pairs() []string { return nil }
+
+// Luno pairs are two three letter codes, eg. XBTZAR
+func (l *lunoExchange) ParseSymbol(symbol string) (Pair, error) {
+	return splitFixedSymbol(symbol)
+}
+
+// Grabs a snapshot of the current luno exchange
+func (l *lunoExchange) Fetch(ctx context.Context) ([]Quote, error) {
+	// Fill the record with the data from the JSON
+	var record LunoTicker
+	if err := fetchJSON(ctx, exchangeConfig(l).URL, &record); err != nil {
+		return nil, err
+	}
+
+	// Format timestamp as string
+	timestampString := strconv.FormatInt(time.Now().Unix(), 10)
+
+	// Loop through the slice
+	var quotes []Quote
+	for i := range record.Tickers {
+		quotes = append(quotes, Quote{
+			Exchange:     l.Name(),
+			Timestamp:    timestampString,
+			Ask:          record.Tickers[i].Ask,
+			Bid:          record.Tickers[i].Bid,
+			Volume:       record.Tickers[i].Rolling24HourVolume,
+			CurrencyCode: record.Tickers[i].Pair[3:],
+			Pair:         symbolPair(l, record.Tickers[i].Pair),
+		})
+	}
+	return quotes, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import "context"
+
+func init() {
+	registerExchange(&okcoinExchange{})
+}
+
+type OKCoin struct {
+	Date   string `json:"date"`
+	Ticker struct {
+		Buy  string `json:"buy"`
+		High string `json:"high"`
+		Last string `json:"last"`
+		Low  string `json:"low"`
+		Sell string `json:"sell"`
+		Vol  string `json:"vol"`
+	} `json:"ticker"`
+}
+
+// OKCoin is queried once per ticker set in the config file
+type okcoinExchange struct{}
+
+func (o *okcoinExchange) Key() string     { return "okcoin" }
+func (o *okcoinExchange) Name() string    { return "OKCoin" }
+func (o *okcoinExchange) Pairs() []string { return splitTickers(exchangeConfig(o).Tickers) }
+
+// OKCoin symbols are separated by an underscore, eg. btc_usd
+func (o *okcoinExchange) ParseSymbol(symbol string) (Pair, error) {
+	return splitSeparatedSymbol(symbol, "_")
+}
+
+// Grabs a snapshot of the current OKCoin exchange
+func (o *okcoinExchange) Fetch(ctx context.Context) ([]Quote, error) {
+	var quotes []Quote
+	var lastErr error
+	for _, ticker := range o.Pairs() {
+		// Fill the record with the data from the JSON
+		var record OKCoin
+		if err := fetchJSON(ctx, exchangeConfig(o).URL+ticker, &record); err != nil {
+			log.Error(err.Error())
+			lastErr = err
+			continue
+		}
+
+		quotes = append(quotes, Quote{
+			Exchange:     o.Name(),
+			Timestamp:    record.Date,
+			Ask:          record.Ticker.Sell,
+			Bid:          record.Ticker.Buy,
+			Volume:       record.Ticker.Vol,
+			CurrencyCode: formatCurrencyString(ticker, "okcoin"),
+			Pair:         symbolPair(o, ticker),
+		})
+	}
+
+	// Only count it as a failure if no ticker could be fetched
+	if len(quotes) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return quotes, nil
+}
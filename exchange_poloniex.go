@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/jyap808/go-poloniex"
+)
+
+func init() {
+	registerExchange(&poloniexExchange{})
+}
+
+// Poloniex returns every ticker it supports in a single call
+type poloniexExchange struct{}
+
+func (p *poloniexExchange) Key() string     { return "poloniex" }
+func (p *poloniexExchange) Name() string    { return "Poloniex" }
+func (p *poloniexExchange) Pairs() []string { return nil }
+func (p *poloniexExchange) usesAPIClient()  {}
+
+// Poloniex lists the quote currency first, so BTC_ETH is ETH priced in BTC
+func (p *poloniexExchange) ParseSymbol(symbol string) (Pair, error) {
+	pair, err := splitSeparatedSymbol(symbol, "_")
+	if err != nil {
+		return Pair{}, err
+	}
+	return Pair{Base: pair.Quote, Quote: pair.Base}, nil
+}
+
+// Grabs a snapshot of the current Poloniex exchange
+func (p *poloniexExchange) Fetch(ctx context.Context) ([]Quote, error) {
+	cfg := exchangeConfig(p)
+
+	// Init Poloniex client
+	polClient := poloniex.New(cfg.APIKey, cfg.APISecret)
+
+	// Get ticker data
+	var tickers map[string]poloniex.Ticker
+	err := withRetry(ctx, p.Name(), func() (err error) {
+		tickers, err = polClient.GetTickers()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a timestamp now
+	ts := strconv.FormatInt(int64(time.Now().Unix()), 10)
+
+	var quotes []Quote
+	for key, ticker := range tickers {
+		// Poloniex's base volume is in the currency we call the quote
+		quotes = append(quotes, Quote{
+			Exchange:     p.Name(),
+			Timestamp:    ts,
+			Ask:          strconv.FormatFloat(ticker.LowestAsk, 'f', 8, 64),
+			Bid:          strconv.FormatFloat(ticker.HighestBid, 'f', 8, 64),
+			Volume:       strconv.FormatFloat(ticker.QuoteVolume, 'f', 8, 64),
+			CurrencyCode: key,
+			Pair:         symbolPair(p, key),
+		})
+	}
+	return quotes, nil
+}
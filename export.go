@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rows read from the database at a time while exporting
+const exportBatchSize = 1000
+
+// Columns of CSV exports
+var exportColumns = []string{"id", "exchange", "base", "quote", "currencyCode", "timestamp", "dateUpdated", "bid", "ask", "mid", "volume"}
+
+// Parses the optional exchange and pair filters of a quote query
+func parseQuoteFilter(exchange string, symbol string) (string, Pair, error) {
+	var pair Pair
+
+	if len(exchange) > 0 {
+		e, err := lookupExchange(exchange)
+		if err != nil {
+			return "", pair, err
+		}
+		exchange = e.Name()
+	}
+
+	if len(symbol) > 0 {
+		var err error
+		if pair, err = parsePair(symbol); err != nil {
+			return "", pair, err
+		}
+	}
+
+	return exchange, pair, nil
+}
+
+// Writes the quotes between from and to to out, as csv or ndjson
+func writeExport(out io.Writer, format string, exchange string, pair Pair, from int64, to int64) error {
+	buf := bufio.NewWriter(out)
+
+	var err error
+	switch format {
+	case "csv":
+		writer := csv.NewWriter(buf)
+		writer.Write(exportColumns)
+		err = store.Export(exchange, pair, from, to, func(row *APIStruct) error {
+			return writer.Write([]string{
+				strconv.FormatInt(row.ID, 10),
+				row.Exchange,
+				row.Base,
+				row.Quote,
+				row.CurrencyCode,
+				strconv.FormatInt(int64(row.Timestamp), 10),
+				row.DateUpdated,
+				strconv.FormatFloat(row.Bid, 'f', -1, 64),
+				strconv.FormatFloat(row.Ask, 'f', -1, 64),
+				strconv.FormatFloat(row.Average, 'f', -1, 64),
+				strconv.FormatFloat(row.Volume, 'f', -1, 64),
+			})
+		})
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+
+	case "ndjson":
+		encoder := json.NewEncoder(buf)
+		err = store.Export(exchange, pair, from, to, func(row *APIStruct) error {
+			return encoder.Encode(row)
+		})
+
+	default:
+		return errors.New("format must be csv or ndjson")
+	}
+
+	if err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+// Content types of the export formats
+var exportContentTypes = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"ndjson": "application/x-ndjson",
+}
+
+// Streams the stored quotes between from and to as CSV or NDJSON,
+// optionally only those of an exchange and of a pair
+func exportQuotes(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	exchange, pair, err := parseQuoteFilter(query.Get("exchange"), query.Get("pair"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	from, err := parseTimeParam(query.Get("from"), 0)
+	if err != nil {
+		http.Error(w, "from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseTimeParam(query.Get("to"), time.Now().Unix())
+	if err != nil {
+		http.Error(w, "to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format := strings.ToLower(query.Get("format"))
+	if len(format) == 0 {
+		format = "csv"
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		http.Error(w, "format must be csv or ndjson", http.StatusBadRequest)
+		return
+	}
+
+	log.Infof("Called: export %s %s %d-%d\n", exchange, pair, from, to)
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="quotes.`+format+`"`)
+
+	// Too late to tell the client once rows have been sent
+	if err := writeExport(w, format, exchange, pair, from, to); err != nil {
+		log.Errorf("Export failed: %s", err.Error())
+	}
+}
+
+// Writes the stored quotes to a file, eg.
+// export -exchange luno -pair BTC-ZAR -from 2017-01-01T00:00:00Z -o january.csv
+func exportCommand(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	exchangeFlag := flags.String("exchange", "", "only export the quotes of this exchange")
+	pairFlag := flags.String("pair", "", "only export the quotes of this pair, eg. BTC-ZAR")
+	fromFlag := flags.String("from", "", "oldest quote to export, unix seconds or RFC3339")
+	toFlag := flags.String("to", "", "newest quote to export, unix seconds or RFC3339")
+	formatFlag := flags.String("format", "", "csv or ndjson, defaults to the extension of -o or csv")
+	outFlag := flags.String("o", "", "file to write to, - for stdout")
+	flags.Parse(args)
+
+	fail := func(format string, a ...interface{}) {
+		fmt.Fprintf(os.Stderr, format+"\n", a...)
+		store.Close()
+		os.Exit(1)
+	}
+
+	if len(*outFlag) == 0 {
+		fail("export: -o is required")
+	}
+
+	exchange, pair, err := parseQuoteFilter(*exchangeFlag, *pairFlag)
+	if err != nil {
+		fail("export: %s", err.Error())
+	}
+	from, err := parseTimeParam(*fromFlag, 0)
+	if err != nil {
+		fail("export: -from: %s", err.Error())
+	}
+	to, err := parseTimeParam(*toFlag, time.Now().Unix())
+	if err != nil {
+		fail("export: -to: %s", err.Error())
+	}
+
+	format := strings.ToLower(*formatFlag)
+	if len(format) == 0 {
+		format = "csv"
+		if strings.HasSuffix(strings.ToLower(*outFlag), ".ndjson") {
+			format = "ndjson"
+		}
+	}
+	if _, ok := exportContentTypes[format]; !ok {
+		fail("export: -format must be csv or ndjson")
+	}
+
+	out := os.Stdout
+	if *outFlag != "-" {
+		if out, err = os.Create(*outFlag); err != nil {
+			fail("export: %s", err.Error())
+		}
+	}
+
+	err = writeExport(out, format, exchange, pair, from, to)
+	if out != os.Stdout {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fail("export: %s", err.Error())
+	}
+}
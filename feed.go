@@ -0,0 +1,89 @@
+package main
+
+import (
+	"sync"
+)
+
+// Fans the rows written by the tickers out to the streaming clients
+var quoteFeed = newQuoteHub()
+
+// A subscriber of the quote feed. Rows matching the filter are sent on C.
+// Subscribers that fall more than their buffer behind are dropped, and C
+// is closed, rather than holding up the tickers.
+type subscription struct {
+	C <-chan *APIStruct
+
+	c      chan *APIStruct
+	hub    *quoteHub
+	match  func(*APIStruct) bool
+	lagged bool
+}
+
+// Lagged reports whether the subscription was dropped for falling behind.
+// Only meaningful once C is closed.
+func (s *subscription) Lagged() bool {
+	s.hub.lock.RLock()
+	defer s.hub.lock.RUnlock()
+	return s.lagged
+}
+
+// Keeps track of the subscribers of the quote feed
+type quoteHub struct {
+	lock        sync.RWMutex
+	subscribers map[*subscription]bool
+}
+
+func newQuoteHub() *quoteHub {
+	return &quoteHub{subscribers: make(map[*subscription]bool)}
+}
+
+// Subscribes to the rows for which match returns true, holding up to
+// buffer rows for a slow reader
+func (h *quoteHub) subscribe(buffer int, match func(*APIStruct) bool) *subscription {
+	c := make(chan *APIStruct, buffer)
+	s := &subscription{C: c, c: c, hub: h, match: match}
+
+	h.lock.Lock()
+	h.subscribers[s] = true
+	h.lock.Unlock()
+
+	return s
+}
+
+// Stops a subscription and closes its channel, if it isn't closed already
+func (h *quoteHub) unsubscribe(s *subscription) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.subscribers[s] {
+		delete(h.subscribers, s)
+		close(s.c)
+	}
+}
+
+// Sends the rows to every matching subscriber without ever blocking
+func (h *quoteHub) publish(rows []*APIStruct) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for s := range h.subscribers {
+		for _, row := range rows {
+			if !s.match(row) {
+				continue
+			}
+
+			select {
+			case s.c <- row:
+			default:
+				// The reader can't keep up, let it go
+				log.Warningf("Dropping a stream subscriber %d rows behind", cap(s.c))
+				s.lagged = true
+				delete(h.subscribers, s)
+				close(s.c)
+			}
+			if s.lagged {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fiat exchange rates, given as units of each currency per unit of base
+type fiatRates struct {
+	base  string
+	rates map[string]float64
+}
+
+// The fiat rates last loaded and when, shared by the API, the analytics
+// job and the alert rules. fiatRatesLoading is closed once the load in
+// progress, if any, has finished.
+var (
+	fiatRatesLock     sync.Mutex
+	cachedFiatRates   *fiatRates
+	fiatRatesLoadedAt time.Time
+	fiatRatesLoading  chan struct{}
+)
+
+// Response of the fiat rate source, eg. {"base":"USD","rates":{"ZAR":18.2}}
+type fiatRatesResponse struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// Returns how many units of to one unit of from buys
+func (f *fiatRates) rate(from string, to string) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+
+	lookup := func(code string) (float64, bool) {
+		if code == f.base {
+			return 1, true
+		}
+		rate, ok := f.rates[code]
+		return rate, ok && rate > 0
+	}
+
+	rateFrom, ok := lookup(from)
+	if !ok {
+		return 0, errors.New("No fiat rate for " + from)
+	}
+	rateTo, ok := lookup(to)
+	if !ok {
+		return 0, errors.New("No fiat rate for " + to)
+	}
+	return rateTo / rateFrom, nil
+}
+
+// Returns the fiat rates, loading them at most once per analytics interval
+// so that API calls and alert checks don't each fetch them from the source.
+// While they are being loaded, the previous rates are returned, and only
+// the very first load is waited for.
+func currentFiatRates() *fiatRates {
+	fiatRatesLock.Lock()
+
+	if cachedFiatRates != nil && (fiatRatesLoading != nil || time.Since(fiatRatesLoadedAt) < config.Analytics.Interval) {
+		defer fiatRatesLock.Unlock()
+		return cachedFiatRates
+	}
+
+	// Wait for the first load to finish
+	if loading := fiatRatesLoading; loading != nil {
+		fiatRatesLock.Unlock()
+		<-loading
+
+		fiatRatesLock.Lock()
+		defer fiatRatesLock.Unlock()
+		return cachedFiatRates
+	}
+
+	// Load the rates without holding up anyone else
+	loading := make(chan struct{})
+	fiatRatesLoading = loading
+	fiatRatesLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.FetchTimeout)
+	defer cancel()
+	rates := loadFiatRates(ctx, config.Analytics)
+
+	fiatRatesLock.Lock()
+	defer fiatRatesLock.Unlock()
+
+	cachedFiatRates = rates
+	fiatRatesLoadedAt = time.Now()
+	fiatRatesLoading = nil
+	close(loading)
+	return rates
+}
+
+// Loads the fiat rates, from the rate source URL if one is set and
+// otherwise from the [analytics.fiatRates] section of the config file.
+// Rates missing from the source are filled in from the config file.
+func loadFiatRates(ctx context.Context, cfg AnalyticsConfig) *fiatRates {
+	resp := &fiatRates{
+		base:  cfg.FiatBase,
+		rates: make(map[string]float64),
+	}
+
+	if len(cfg.FiatRatesURL) > 0 {
+		var record fiatRatesResponse
+		if err := fetchJSON(ctx, cfg.FiatRatesURL, &record); err != nil {
+			log.Warningf("Could not fetch fiat rates: %s", err.Error())
+		} else {
+			if len(record.Base) > 0 {
+				resp.base = strings.ToUpper(record.Base)
+			}
+			for code, rate := range record.Rates {
+				resp.rates[strings.ToUpper(code)] = rate
+			}
+		}
+	}
+
+	// Only use the configured rates if they share the same base
+	if resp.base == cfg.FiatBase {
+		for code, rate := range cfg.FiatRates {
+			if _, ok := resp.rates[code]; !ok {
+				resp.rates[code] = rate
+			}
+		}
+	}
+
+	return resp
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCurrentFiatRatesDoesNotWaitForReload(t *testing.T) {
+	// The first request is answered straight away, the others hang
+	release := make(chan struct{})
+	var requests int32
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&requests, 1) > 1 {
+			<-release
+		}
+		w.Write([]byte(`{"base":"USD","rates":{"ZAR":18.2}}`))
+	}))
+	defer source.Close()
+	defer close(release)
+
+	config = Config{
+		FetchTimeout:   10 * time.Second,
+		RequestTimeout: 10 * time.Second,
+		Analytics: AnalyticsConfig{
+			Interval:     time.Hour,
+			FiatRatesURL: source.URL,
+			FiatBase:     "USD",
+		},
+	}
+	cachedFiatRates, fiatRatesLoading = nil, nil
+
+	if got, _ := currentFiatRates().rate("USD", "ZAR"); got != 18.2 {
+		t.Fatalf("expected the first load to return 18.2, got %g", got)
+	}
+
+	// Let the rates expire and start a reload that hangs
+	fiatRatesLock.Lock()
+	fiatRatesLoadedAt = time.Now().Add(-2 * time.Hour)
+	fiatRatesLock.Unlock()
+	go currentFiatRates()
+	for loading := false; !loading; {
+		time.Sleep(time.Millisecond)
+		fiatRatesLock.Lock()
+		loading = fiatRatesLoading != nil
+		fiatRatesLock.Unlock()
+	}
+
+	done := make(chan float64)
+	go func() {
+		got, _ := currentFiatRates().rate("USD", "ZAR")
+		done <- got
+	}()
+	select {
+	case got := <-done:
+		if got != 18.2 {
+			t.Fatalf("expected the previous rate of 18.2 during the reload, got %g", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waited for the reload")
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// When the process started, used as the age of exchanges with no data yet
+var startedAt = time.Now()
+
+// How long the database may take to answer a readiness check
+const readinessTimeout = 2 * time.Second
+
+// Outcome of the latest fetches of an exchange
+type fetchStatus struct {
+	lastSuccess time.Time
+	lastError   string
+	lastErrorAt time.Time
+}
+
+// Fetch outcomes and timestamps of the newest stored quote per exchange
+// name. Readiness is worked out from these rather than by querying the
+// ever growing quote table on every probe.
+var (
+	fetchStatusLock sync.Mutex
+	fetchStatuses   = make(map[string]*fetchStatus)
+	newestQuotes    = make(map[string]int64)
+)
+
+// Remembers the outcome of fetching an exchange
+func recordFetchStatus(exchange string, err error) {
+	fetchStatusLock.Lock()
+	defer fetchStatusLock.Unlock()
+
+	status, ok := fetchStatuses[exchange]
+	if !ok {
+		status = &fetchStatus{}
+		fetchStatuses[exchange] = status
+	}
+
+	if err != nil {
+		status.lastError = err.Error()
+		status.lastErrorAt = time.Now()
+		return
+	}
+	status.lastSuccess = time.Now()
+}
+
+// Remembers the timestamps of newly stored quotes, whether this process
+// polled them or another one did
+func recordNewestQuotes(rows []*APIStruct) {
+	fetchStatusLock.Lock()
+	defer fetchStatusLock.Unlock()
+
+	for _, row := range rows {
+		if timestamp := int64(row.Timestamp); timestamp > newestQuotes[row.Exchange] {
+			newestQuotes[row.Exchange] = timestamp
+		}
+	}
+}
+
+// Health of a single exchange
+type ExchangeHealth struct {
+	Exchange    string  `json:"exchange"`
+	Interval    string  `json:"interval"`
+	LastSuccess string  `json:"lastSuccess,omitempty"`
+	LastError   string  `json:"lastError,omitempty"`
+	LastErrorAt string  `json:"lastErrorAt,omitempty"`
+	DataAge     float64 `json:"dataAge"`
+	MaxAge      float64 `json:"maxAge"`
+	Stale       bool    `json:"stale"`
+}
+
+// Response of /readyz
+type Readiness struct {
+	Ready     bool              `json:"ready"`
+	Database  string            `json:"database"`
+	Exchanges []*ExchangeHealth `json:"exchanges"`
+}
+
+// The process is up and serving requests
+func healthz(w http.ResponseWriter, req *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// The database can be reached and the data of every enabled exchange is
+// fresh. Data is stale once it is older than staleAfter times the
+// exchange's polling interval.
+func readyz(w http.ResponseWriter, req *http.Request) {
+	data := checkReadiness(req.Context(), time.Now())
+
+	if !data.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(data)
+}
+
+// Works out whether the service is ready
+func checkReadiness(ctx context.Context, now time.Time) *Readiness {
+	data := &Readiness{Ready: true, Database: "ok", Exchanges: []*ExchangeHealth{}}
+
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	if err := store.Ping(ctx); err != nil {
+		data.Ready = false
+		data.Database = err.Error()
+	}
+
+	fetchStatusLock.Lock()
+	defer fetchStatusLock.Unlock()
+
+	for _, exchange := range registeredExchanges() {
+		if !exchangeConfig(exchange).Enabled {
+			continue
+		}
+
+		interval := exchangeInterval(exchange)
+		health := &ExchangeHealth{
+			Exchange: exchange.Name(),
+			Interval: interval.String(),
+			MaxAge:   (time.Duration(config.StaleAfter * float64(interval))).Seconds(),
+		}
+
+		// Exchanges without any data since startup get until they are due
+		fresh := startedAt
+		if timestamp, ok := newestQuotes[exchange.Name()]; ok {
+			fresh = time.Unix(timestamp, 0)
+		}
+
+		if status, ok := fetchStatuses[exchange.Name()]; ok {
+			// A fetch that succeeded without any quotes, as Kraken's does
+			// without API keys, is as fresh as it gets
+			if status.lastSuccess.After(fresh) {
+				fresh = status.lastSuccess
+			}
+			if !status.lastSuccess.IsZero() {
+				health.LastSuccess = status.lastSuccess.UTC().Format(time.RFC3339)
+			}
+			if !status.lastErrorAt.IsZero() {
+				health.LastError = status.lastError
+				health.LastErrorAt = status.lastErrorAt.UTC().Format(time.RFC3339)
+			}
+		}
+
+		health.DataAge = now.Sub(fresh).Seconds()
+
+		// The ages can't be trusted without the database
+		if data.Database == "ok" && health.DataAge > health.MaxAge {
+			health.Stale = true
+			data.Ready = false
+		}
+
+		data.Exchanges = append(data.Exchanges, health)
+	}
+	sort.Slice(data.Exchanges, func(i, j int) bool { return data.Exchanges[i].Exchange < data.Exchanges[j].Exchange })
+
+	return data
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Default and maximum number of ticks returned per page
+const (
+	defaultHistoryLimit = 100
+	maxHistoryLimit     = 1000
+)
+
+// Get the stored ticks of an exchange within a time range. The ticks are
+// returned oldest first, a page at a time. If there are more ticks, the
+// X-Next-Cursor header holds the cursor to pass in for the next page.
+func getExchangeHistory(w http.ResponseWriter, req *http.Request) {
+
+	var (
+		params = mux.Vars(req)
+		query  = req.URL.Query()
+	)
+
+	market, err := parseMarket(params["exchange"], params["currencyCode"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	from, err := parseTimeParam(query.Get("from"), 0)
+	if err != nil {
+		http.Error(w, "from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseTimeParam(query.Get("to"), time.Now().Unix())
+	if err != nil {
+		http.Error(w, "to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, err := parseIntParam(query.Get("limit"), defaultHistoryLimit)
+	if err != nil || limit <= 0 {
+		http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+		return
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+	cursor, err := parseIntParam(query.Get("cursor"), 0)
+	if err != nil {
+		http.Error(w, "cursor must be a number", http.StatusBadRequest)
+		return
+	}
+
+	// Ask for one row more than the limit to see if there is another page
+	data, err := store.History(market, from, to, cursor, limit+1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Drop the extra row and point the cursor at the last returned row
+	var next int64
+	if int64(len(data)) > limit {
+		data = data[:limit]
+		next = data[limit-1].ID
+	}
+
+	log.Infof("Called: %s history\n", market)
+
+	// Let the client know where the next page starts
+	if next > 0 {
+		w.Header().Set("X-Next-Cursor", strconv.FormatInt(next, 10))
+	}
+
+	json.NewEncoder(w).Encode(data)
+}
+
+// Parses a time query parameter given either as unix seconds or RFC3339,
+// returning the default if the parameter is empty
+func parseTimeParam(value string, def int64) (int64, error) {
+	if len(value) == 0 {
+		return def, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return seconds, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, errors.New("expected unix seconds or RFC3339 time")
+	}
+	return t.Unix(), nil
+}
+
+// Parses an integer query parameter, returning the default if it is empty
+func parseIntParam(value string, def int64) (int64, error) {
+	if len(value) == 0 {
+		return def, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
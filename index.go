@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Ways of combining quotes into an index price
+const (
+	indexVWAP    = "vwap"
+	indexMedian  = "median"
+	indexTrimmed = "trimmed"
+)
+
+// Default share of quotes dropped from each end by the trimmed mean
+const defaultIndexTrim = 0.2
+
+// Get a single reference price for a pair, combining the latest quotes of
+// every exchange that lists it
+func getPriceIndex(w http.ResponseWriter, req *http.Request) {
+
+	var (
+		params = mux.Vars(req)
+		query  = req.URL.Query()
+	)
+
+	pair, err := parsePair(params["pair"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Default to the volume-weighted mean
+	method := query.Get("method")
+	if len(method) == 0 {
+		method = indexVWAP
+	}
+
+	trim := defaultIndexTrim
+	if value := query.Get("trim"); len(value) > 0 {
+		trim, err = strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(trim) || math.IsInf(trim, 0) || trim < 0 || trim >= 0.5 {
+			http.Error(w, "trim must be at least 0 and below 0.5", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// A fixed maximum age, rather than one based on each exchange's interval
+	var maxAge time.Duration
+	if value := query.Get("maxAge"); len(value) > 0 {
+		maxAge, err = time.ParseDuration(value)
+		if err != nil || maxAge <= 0 {
+			http.Error(w, "maxAge must be a positive duration, eg. 15m", http.StatusBadRequest)
+			return
+		}
+	}
+
+	quotes, err := store.LatestQuotes(pair)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, err := computeIndex(pair, quotes, method, trim, maxAge, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Infof("Called: index %s %s\n", pair, method)
+
+	json.NewEncoder(w).Encode(data)
+}
+
+// Returns how old a quote may be before it is left out. Unless maxAge is
+// set, that is twice the polling interval of the exchange it came from.
+func quoteMaxAge(exchange string, maxAge time.Duration) time.Duration {
+	if maxAge > 0 {
+		return maxAge
+	}
+	if e, err := lookupExchange(exchange); err == nil {
+		return 2 * exchangeInterval(e)
+	}
+	return 2 * config.Interval
+}
+
+// Combines the quotes into an index price, leaving out stale quotes
+func computeIndex(pair Pair, quotes []*APIStruct, method string, trim float64, maxAge time.Duration, now time.Time) (*PriceIndex, error) {
+	resp := &PriceIndex{
+		Pair:         pair.String(),
+		Method:       method,
+		Contributors: []*IndexContributor{},
+		Excluded:     []*IndexContributor{},
+	}
+
+	// Split the quotes into fresh ones and stale ones
+	var fresh []*IndexContributor
+	for _, q := range quotes {
+		c := &IndexContributor{
+			Exchange:    q.Exchange,
+			Price:       q.Average,
+			Volume:      q.Volume,
+			DateUpdated: q.DateUpdated,
+		}
+
+		// A volume that isn't a number counts as none, and can't be encoded
+		if !isFinite(c.Volume) {
+			c.Volume = 0
+		}
+
+		age := now.Sub(time.Unix(int64(q.Timestamp), 0))
+		switch {
+		case age > quoteMaxAge(q.Exchange, maxAge):
+			c.Reason = "stale"
+			resp.Excluded = append(resp.Excluded, c)
+		case !isFinite(q.Average) || q.Average <= 0:
+			// Reported as zero, as a price that isn't a number can't be encoded
+			c.Price = 0
+			c.Reason = "no price"
+			resp.Excluded = append(resp.Excluded, c)
+		default:
+			fresh = append(fresh, c)
+		}
+	}
+
+	if len(fresh) == 0 {
+		return nil, errors.New("No fresh quotes for " + pair.String())
+	}
+
+	// Order by price, which the median and trimmed mean rely on
+	sort.Slice(fresh, func(i, j int) bool { return fresh[i].Price < fresh[j].Price })
+
+	// Quotes left without any weight are excluded for this reason
+	var reason string
+	switch method {
+	case indexVWAP:
+		weighVolume(fresh)
+		reason = "no volume"
+	case indexMedian:
+		weighMedian(fresh)
+		reason = "not the median"
+	case indexTrimmed:
+		weighTrimmed(fresh, trim)
+		reason = "trimmed"
+	default:
+		return nil, errors.New("method must be one of vwap, median or trimmed")
+	}
+
+	// The index is the weighted mean of the contributors
+	for _, c := range fresh {
+		resp.Price += c.Price * c.Weight
+		if c.Weight > 0 {
+			resp.Contributors = append(resp.Contributors, c)
+		} else {
+			c.Reason = reason
+			resp.Excluded = append(resp.Excluded, c)
+		}
+	}
+	resp.Price = math.Round(resp.Price*1e8) / 1e8
+	resp.DateUpdated = now.UTC().Format("2006-01-02 15:04:05")
+
+	return resp, nil
+}
+
+// Weighs each quote by its share of the volume. Without any volume, the
+// quotes are weighed equally.
+func weighVolume(quotes []*IndexContributor) {
+	total := 0.0
+	for _, c := range quotes {
+		total += c.Volume
+	}
+	if total <= 0 {
+		weighEqually(quotes)
+		return
+	}
+	for _, c := range quotes {
+		c.Weight = c.Volume / total
+	}
+}
+
+// Gives all the weight to the middle quote, or splits it between the two
+// middle quotes. The quotes must be sorted by price.
+func weighMedian(quotes []*IndexContributor) {
+	n := len(quotes)
+	if n%2 == 1 {
+		quotes[n/2].Weight = 1
+		return
+	}
+	quotes[n/2-1].Weight = 0.5
+	quotes[n/2].Weight = 0.5
+}
+
+// Drops the given share of quotes from each end and weighs the rest
+// equally, always keeping at least one. The quotes must be sorted by price.
+func weighTrimmed(quotes []*IndexContributor, trim float64) {
+	most := (len(quotes) - 1) / 2
+	if most < 0 {
+		return
+	}
+
+	// Compare as floats, an infinite trim doesn't convert to an int
+	drop := 0
+	if trim > 0 {
+		drop = most
+		if share := math.Floor(float64(len(quotes)) * trim); share < float64(most) {
+			drop = int(share)
+		}
+	}
+	weighEqually(quotes[drop : len(quotes)-drop])
+}
+
+// Whether a number is neither NaN nor infinite
+func isFinite(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
+
+// Weighs the quotes equally
+func weighEqually(quotes []*IndexContributor) {
+	for _, c := range quotes {
+		c.Weight = 1 / float64(len(quotes))
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestComputeIndex(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	quote := func(exchange string, price float64, volume float64, age time.Duration) *APIStruct {
+		return &APIStruct{
+			Exchange:  exchange,
+			Average:   price,
+			Volume:    volume,
+			Timestamp: float64(now.Add(-age).Unix()),
+		}
+	}
+
+	quotes := []*APIStruct{
+		quote("Luno", 100, 1, 0),
+		quote("Bitstamp", 110, 3, time.Minute),
+		quote("Bitfinex", 130, 0, 0),
+		quote("OKCoin", 90, 0, 0),
+		quote("BTCC", 1000, 5, time.Hour),
+	}
+
+	cases := []struct {
+		name     string
+		quotes   []*APIStruct
+		method   string
+		trim     float64
+		price    float64
+		excluded int
+		err      bool
+	}{
+		{"vwap", quotes, indexVWAP, 0, 107.5, 3, false},
+		{"median", quotes, indexMedian, 0, 105, 3, false},
+		{"trimmed", quotes, indexTrimmed, 0.25, 105, 3, false},
+		{"trimmed without trim", quotes, indexTrimmed, 0, 107.5, 1, false},
+		{"trimmed nan", quotes, indexTrimmed, math.NaN(), 107.5, 1, false},
+		{"trimmed infinite", quotes, indexTrimmed, math.Inf(1), 105, 3, false},
+		{"trimmed negative infinite", quotes, indexTrimmed, math.Inf(-1), 107.5, 1, false},
+		{"vwap without volume", quotes[2:4], indexVWAP, 0, 110, 0, false},
+		{"nan price", []*APIStruct{quote("Luno", math.NaN(), 1, 0), quote("Bitstamp", 110, 1, 0)}, indexVWAP, 0, 110, 1, false},
+		{"infinite price", []*APIStruct{quote("Luno", math.Inf(1), 1, 0), quote("Bitstamp", 110, 1, 0)}, indexVWAP, 0, 110, 1, false},
+		{"nan volume", []*APIStruct{quote("Luno", 100, math.NaN(), 0), quote("Bitstamp", 110, 1, 0)}, indexVWAP, 0, 110, 1, false},
+		{"infinite volume", []*APIStruct{quote("Luno", 100, math.Inf(1), 0), quote("Bitstamp", 110, 1, 0)}, indexVWAP, 0, 110, 1, false},
+		{"only stale", quotes[4:], indexVWAP, 0, 0, 0, true},
+		{"empty", nil, indexVWAP, 0, 0, 0, true},
+		{"unknown method", quotes, "mean", 0, 0, 0, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			index, err := computeIndex(Pair{Base: "BTC", Quote: "USD"}, c.quotes, c.method, c.trim, 30*time.Minute, now)
+			if c.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", index)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if index.Price != c.price {
+				t.Errorf("expected a price of %g, got %g", c.price, index.Price)
+			}
+			if len(index.Excluded) != c.excluded {
+				t.Errorf("expected %d excluded, got %d", c.excluded, len(index.Excluded))
+			}
+			if _, err := json.Marshal(index); err != nil {
+				t.Errorf("index can't be encoded: %s", err)
+			}
+		})
+	}
+}
+
+func TestWeighTrimmed(t *testing.T) {
+	cases := []struct {
+		name   string
+		quotes int
+		trim   float64
+		// Number of quotes left with a weight
+		kept int
+	}{
+		{"no trim", 5, 0, 5},
+		{"one from each end", 5, 0.2, 3},
+		{"rounds down", 5, 0.39, 3},
+		{"keeps the middle", 5, 0.49, 1},
+		{"keeps the middle two", 4, 0.49, 2},
+		{"single quote", 1, 0.49, 1},
+		{"nan", 5, math.NaN(), 5},
+		{"infinite", 5, math.Inf(1), 1},
+		{"negative infinite", 5, math.Inf(-1), 5},
+		{"empty", 0, 0.2, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			quotes := make([]*IndexContributor, c.quotes)
+			for i := range quotes {
+				quotes[i] = &IndexContributor{Price: float64(i)}
+			}
+			weighTrimmed(quotes, c.trim)
+
+			kept, total := 0, 0.0
+			for _, q := range quotes {
+				if q.Weight > 0 {
+					kept++
+				}
+				total += q.Weight
+			}
+			if kept != c.kept {
+				t.Errorf("expected %d quotes kept, got %d", c.kept, kept)
+			}
+			if kept > 0 && math.Abs(total-1) > 1e-9 {
+				t.Errorf("expected the weights to add up to 1, got %g", total)
+			}
+		})
+	}
+}
@@ -1,22 +1,18 @@
 package main
 
 import (
-	"database/sql"
+	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"net/http"
 	"os"
-	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/Beldur/kraken-go-api-client"
 	"github.com/fsnotify/fsnotify"
 	"github.com/gorilla/mux"
-	"github.com/jyap808/go-poloniex"
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
 )
@@ -38,13 +34,6 @@ var format = logging.MustStringFormatter(
 	`%{color}%{time:15:04:05.000} %{shortfunc} ▶ %{level:.4s} %{id:03x}%{color:reset} %{message}`,
 )
 
-// Setup global home and config folders
-// File location
-var fileLocation string = "/.config/kyco.bitcoin.currency.tickers/"
-
-// Get home folder
-var home string = os.Getenv("HOME") + fileLocation
-
 // Get Exchange rate based on an API call
 func get_exchange_rate(w http.ResponseWriter, req *http.Request) {
 
@@ -52,13 +41,19 @@ func get_exchange_rate(w http.ResponseWriter, req *http.Request) {
 		params = mux.Vars(req)
 	)
 
-	data, err := queryExchangeSQLite(params["exchange"], params["currencyCode"])
+	market, err := parseMarket(params["exchange"], params["currencyCode"])
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	log.Infof("Called: %s -> %s\n", params["exchange"], params["currencyCode"])
+	data, err := store.Latest(market)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Infof("Called: %s\n", market)
 
 	json.NewEncoder(w).Encode(data)
 }
@@ -75,11 +70,12 @@ func show_exchange_methods(w http.ResponseWriter, req *http.Request) {
 	url = "http://" + req.Header.Get("X-Forwarded-Server") + "/" + params["exchange"] + "/"
 
 	// Grab exchange data
-	data, err := queryExchangeCurrencyCodesSQLite(params["exchange"], url)
+	codes, err := store.CurrencyCodes(params["exchange"])
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	data := prefixURL(url, codes)
 
 	log.Infof("Called: %s\n", params["exchange"])
 
@@ -98,11 +94,12 @@ func showExchanges(w http.ResponseWriter, req *http.Request) {
 	url = "http://" + req.Header.Get("X-Forwarded-Server") + "/"
 
 	// Grab exchange data
-	data, err := queryListOfExchanges(url)
+	exchanges, err := store.Exchanges()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	data := prefixURL(url, exchanges)
 
 	log.Infof("Called Root Page")
 
@@ -110,360 +107,66 @@ func showExchanges(w http.ResponseWriter, req *http.Request) {
 	json.NewEncoder(w).Encode(data)
 }
 
-// Initialises various bitcoin price tickers
-func bitcoinPrices() {
-
-	// Tick on the minute
-	// t := minuteTicker()
-
-	for {
-
-		// wait for the tick
-		// <-t.C
-
-		// Start luno ticker
-		lunoTicker()
-		log.Notice("Ran Luno Ticker")
-
-		// Start bitstamp ticker
-		bitstampTicker()
-		log.Notice("Ran Bitstamp Ticker")
-
-		// Start kraken ticker
-		krakenTicker()
-		log.Notice("Ran Kraken Ticker")
-
-		// Start bitfinex ticker
-		bitfinexTicker()
-		log.Notice("Ran Bitfinex Ticker")
-
-		// Start bitsquare ticker
-		bitsquareTicker()
-		log.Notice("Ran Bitsquare Ticker")
-
-		// Start btcc ticker
-		btccTicker()
-		log.Notice("Ran BTCChina Ticker")
-
-		// Start okcoin ticker
-		okcoinTicker()
-		log.Notice("Ran OKCoin Ticker")
-
-		// Start poloniex ticker
-		poloniexTicker()
-		log.Notice("Ran Poloniex Ticker")
-
-		time.Sleep(10 * time.Minute)
-
-	}
-
-}
-
-// Grabs a snapshot of the current luno exchange
-func lunoTicker() {
-	// Make API call to luno
-	resp := apiCall(config.Luno.URL)
-	// If an empty response was returned
-	if resp == nil {
-		return
-	}
-
-	// Callers should close resp.Body
-	// when done reading from it
-	// Defer the closing of the body
-	defer resp.Body.Close()
-
-	// Fill the record with the data from the JSON
-	var record LunoTicker
-
-	// Use json.Decode for reading streams of JSON data
-	if err := json.NewDecoder(resp.Body).Decode(&record); err != nil {
-		log.Error(err.Error())
-	} else {
-		// Write to DB
-		// Loop through the slice
-		for i := range record.Tickers {
-			// Format timestamp as string
-			timestampString := strconv.FormatInt(time.Now().Unix(), 10)
-			insertIntoSQLite("Luno", timestampString, record.Tickers[i].Ask, record.Tickers[i].Bid, "1", record.Tickers[i].Pair[3:])
-		}
-	}
-}
-
-// Grabs a snapshot of the current bitstamp exchange
-func bitstampTicker() {
-	// Make API call to bitstamp
-	resp := apiCall(config.Bitstamp.URL)
-
-	// If an empty response was returned
-	if resp == nil {
-		return
-	}
-
-	// Callers should close resp.Body
-	// when done reading from it
-	// Defer the closing of the body
-	defer resp.Body.Close()
-
-	// Fill the record with the data from the JSON
-	var record Bitstamp
-
-	// Use json.Decode for reading streams of JSON data
-	if err := json.NewDecoder(resp.Body).Decode(&record); err != nil {
-		log.Error(err.Error())
-	} else {
-		// Insert into SQlite
-		insertIntoSQLite("Bitstamp", record.Timestamp, record.Ask, record.Bid, record.Volume, "USD")
-	}
-}
-
-// Gets ticker data from kraken
-func krakenTicker() {
-	// If the API keys are not present, just return
-	if len(config.Kraken.APIKey) == 0 && len(config.Kraken.APISecret) == 0 {
-		return
-	}
-
-	api := krakenapi.New(config.Kraken.APIKey, config.Kraken.APISecret)
-
-	// There are also some strongly typed methods available
-	ticker, err := api.Ticker(krakenapi.XXBTZEUR, krakenapi.XXBTZUSD, krakenapi.XXBTZGBP, krakenapi.DASHXBT, krakenapi.XETCXXBT, krakenapi.XLTCXXBT)
-	if err != nil {
-		log.Error(err.Error())
-	} else {
-
-		v := reflect.ValueOf(ticker).Elem()
-		typeOfT := v.Type()
-		for j := 0; j < v.NumField(); j++ {
-
-			f := v.Field(j)
-			inter := f.Interface().(krakenapi.PairTickerInfo)
-
-			// Check if the ask value is empty
-			if len(inter.Ask) > 0 {
-
-				// Insert into SQlite
-				insertIntoSQLite("Kraken", strconv.FormatInt(int64(time.Now().Unix()), 10), inter.Ask[0], inter.Bid[0], inter.Volume[0], formatCurrencyString(typeOfT.Field(j).Name, "Kraken"))
-			}
-		}
-	}
-}
-
-// Grabs a snapshot of the current bitfinex exchange
-func bitfinexTicker() {
-	// In this case, we will loop through all
-	// the tickers set in the config file
-	tickerSplit := strings.Split(config.Bitfinex.Tickers, ",")
-
-	for i := range tickerSplit {
-
-		// Check if there is any data in the string
-		// if not, skip this loop
-		if len(tickerSplit[i]) < 2 {
-			continue
-		}
-
-		// Make API call to bitfinex
-		resp := apiCall(config.Bitfinex.URL + tickerSplit[i])
-
-		// If an empty response was returned
-		if resp == nil {
-			continue
-		}
-
-		// Callers should close resp.Body
-		// when done reading from it
-		// Defer the closing of the body
-		defer resp.Body.Close()
-
-		// Fill the record with the data from the JSON
-		var record Bitfinex
-
-		// Use json.Decode for reading streams of JSON data
-		if err := json.NewDecoder(resp.Body).Decode(&record); err != nil {
-			log.Error(err.Error())
-		} else {
-			// Insert into SQlite
-			insertIntoSQLite("Bitfinex", record.Timestamp, record.Ask, record.Bid, record.Volume, formatCurrencyString(tickerSplit[i], "Bitfinex"))
-		}
-	}
-}
-
-// Grabs a snapshot of the current bitsquare exchange
-func bitsquareTicker() {
-	// In this case, we will loop through all
-	// the tickers set in the config file
-	tickerSplit := strings.Split(config.Bitsquare.Tickers, ",")
+// performs an API call to a URL and returns a JSON body response
+// the request is cancelled once ctx is done. Network errors, 5xx and
+// 429 responses are retried with exponential backoff.
+func apiCall(ctx context.Context, urlRequest string) *http.Response {
 
-	for i := range tickerSplit {
+	url := urlRequest
 
-		// Check if there is any data in the string
-		// if not, skip this loop
-		if len(tickerSplit[i]) < 2 {
-			continue
-		}
+	// For control over HTTP client headers,
+	// redirect policy, and other settings,
+	// create a Client
+	// A Client is an HTTP client
+	client := &http.Client{Timeout: config.RequestTimeout}
 
-		// Make API call to bitsquare
-		resp := apiCall(config.Bitsquare.URL + tickerSplit[i])
+	for attempt := 0; ; attempt++ {
 
-		// If an empty response was returned
-		if resp == nil {
-			continue
+		// Build the request
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			log.Error("NewRequest: ", err)
+			return nil
 		}
 
-		// Callers should close resp.Body
-		// when done reading from it
-		// Defer the closing of the body
-		defer resp.Body.Close()
+		// Cancel the request when the fetch deadline passes
+		req = req.WithContext(ctx)
 
-		// Fill the record with the data from the JSON
-		var record []Bitsquare
+		// Send the request via a client
+		// Do sends an HTTP request and
+		// returns an HTTP response
+		resp, err := client.Do(req)
 
-		// Use json.Decode for reading streams of JSON data
-		if err := json.NewDecoder(resp.Body).Decode(&record); err != nil {
-			log.Error(err.Error())
+		// Work out whether this attempt is worth retrying
+		var wait time.Duration
+		if err != nil {
+			log.Error("Do: ", err)
+			wait = retryBackoff(attempt)
+		} else if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			log.Warningf("%s returned %s", url, resp.Status)
+			wait = retryAfter(resp, attempt)
+			resp.Body.Close()
 		} else {
-			// Create a timestamp now
-			ts := strconv.FormatInt(int64(time.Now().Unix()), 10)
-			// Insert into SQlite
-			insertIntoSQLite("Bitsquare", ts, record[0].Sell, record[0].Buy, record[0].VolumeRight, formatCurrencyString(tickerSplit[i], "Bitsquare"))
+			// Return the body
+			return resp
 		}
-	}
-}
 
-// Grabs a snapshot of the current BTCC exchange
-func btccTicker() {
-	// In this case, we will loop through all
-	// the tickers set in the config file
-	tickerSplit := strings.Split(config.BTCC.Tickers, ",")
-
-	for i := range tickerSplit {
-
-		// Check if there is any data in the string
-		// if not, skip this loop
-		if len(tickerSplit[i]) < 2 {
-			continue
+		// Give up once the retries are used up
+		if attempt >= config.Retries {
+			log.Errorf("Giving up on %s after %d attempts", url, attempt+1)
+			return nil
 		}
 
-		// Make API call to btcc
-		resp := apiCall(config.BTCC.URL + tickerSplit[i])
-
-		// Callers should close resp.Body
-		// when done reading from it
-		// Defer the closing of the body
-		defer resp.Body.Close()
-
-		// Fill the record with the data from the JSON
-		var record BTCC
-
-		// Use json.Decode for reading streams of JSON data
-		if err := json.NewDecoder(resp.Body).Decode(&record); err != nil {
-			log.Error(err.Error())
-		} else {
-			// Insert into SQlite
-			insertIntoSQLite("BTCChina", strconv.FormatInt((record.Ticker.Timestamp/1000), 10), strconv.FormatFloat(record.Ticker.AskPrice, 'f', 2, 64), strconv.FormatFloat(record.Ticker.BidPrice, 'f', 2, 64), strconv.FormatFloat(record.Ticker.Volume, 'f', 2, 64), formatCurrencyString(tickerSplit[i], "btcc"))
+		// Wait before trying again, unless the deadline passes first
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			log.Error("Do: ", ctx.Err())
+			return nil
 		}
 	}
 }
 
-// Grabs a snapshot of the current OKCoin exchange
-func okcoinTicker() {
-	// In this case, we will loop through all
-	// the tickers set in the config file
-	tickerSplit := strings.Split(config.OKCoin.Tickers, ",")
-
-	for i := range tickerSplit {
-
-		// Check if there is any data in the string
-		// if not, skip this loop
-		if len(tickerSplit[i]) < 2 {
-			continue
-		}
-
-		// Make API call to OKCoin
-		resp := apiCall(config.OKCoin.URL + tickerSplit[i])
-
-		// If an empty response was returned
-		if resp == nil {
-			continue
-		}
-
-		// Callers should close resp.Body
-		// when done reading from it
-		// Defer the closing of the body
-		defer resp.Body.Close()
-
-		// Fill the record with the data from the JSON
-		var record OKCoin
-
-		// Use json.Decode for reading streams of JSON data
-		if err := json.NewDecoder(resp.Body).Decode(&record); err != nil {
-			log.Error(err.Error())
-		} else {
-			// Insert into SQlite
-			insertIntoSQLite("OKCoin", record.Date, record.Ticker.Sell, record.Ticker.Buy, record.Ticker.Vol, formatCurrencyString(tickerSplit[i], "okcoin"))
-		}
-	}
-}
-
-// Grabs a snapshot of the current Poloniex exchange
-func poloniexTicker() {
-
-	// Init Poloniex client
-	polClient := poloniex.New(config.Poloniex.APIKey, config.Poloniex.APISecret)
-
-	// Get ticker data
-	tickers, err := polClient.GetTickers()
-
-	// Check if we had an error, if we did, log it
-	if err != nil {
-		log.Error(err.Error())
-	} else {
-		// Create a timestamp now
-		ts := strconv.FormatInt(int64(time.Now().Unix()), 10)
-		for key, ticker := range tickers {
-			// Insert into SQlite
-			insertIntoSQLite("Poloniex", ts, strconv.FormatFloat(ticker.LowestAsk, 'f', 8, 64), strconv.FormatFloat(ticker.HighestBid, 'f', 8, 64), strconv.FormatFloat(ticker.BaseVolume, 'f', 8, 64), key)
-		}
-	}
-}
-
-// performs an API call to a URL and returns a JSON body response
-func apiCall(urlRequest string) *http.Response {
-
-	url := fmt.Sprintf(urlRequest)
-
-	// Build the request
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		log.Error("NewRequest: ", err)
-		return nil
-	}
-
-	// For control over HTTP client headers,
-	// redirect policy, and other settings,
-	// create a Client
-	// A Client is an HTTP client
-	client := &http.Client{}
-
-	// Send the request via a client
-	// Do sends an HTTP request and
-	// returns an HTTP response
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Error("Do: ", err)
-		return nil
-	}
-
-	// // Callers should close resp.Body
-	// // when done reading from it
-	// // Defer the closing of the body
-	// defer resp.Body.Close()
-
-	// Return the body
-	return resp
-}
-
 // formats the currency code into something more standard
 func formatCurrencyString(currencyCode string, exchange string) string {
 	// Replace BTC
@@ -488,163 +191,13 @@ func check(e error) {
 	}
 }
 
-// Open SQlite Connection
-func sqliteOpen() *sql.DB {
-	db, err := sql.Open("sqlite3", home+"/data.db")
-	if err != nil {
-		log.Error(err.Error())
-	}
-	return db
-}
-
-// Sets up the sqlite databases and connections
-func setupSQLiteDB() {
-	// Setup sqlite connection
-	var sqliteConnection string
-
-	// If the config file line is empty, then default
-	if len(config.SqliteLocation) > 0 {
-		sqliteConnection = config.SqliteLocation
-	} else {
-		sqliteConnection = home + "/data.db"
-	}
-	// Check if the sqlite database already exists, if it does not, continue
-	// else, don't care
-	if _, err := os.Stat(sqliteConnection); os.IsNotExist(err) {
-		sqliteDB := sqliteOpen()
-
-		defer sqliteDB.Close() // Don't forget to close
-
-		sqlStmt := `create table exchanges (id integer not null primary key, exchange text, timestamp real, ask real, bid real, volume real default 0, currencyCode text);`
-		_, err = sqliteDB.Exec(sqlStmt)
-		if err != nil {
-			log.Warning("%q: %s\n", err, sqlStmt)
-			return
-		}
-	}
-}
-
-// Insert function into sqlite
-func insertIntoSQLite(exchange string, timestamp string, ask string, bid string, volume string, currencyCode string) {
-
-	// If the exchange name is not there, ignore, otherwise run
-	if len(exchange) > 0 && len(currencyCode) > 0 {
-
-		// Clean strings, if the string doesn't contain anything, default
-		cleanStrings(&timestamp, &ask, &bid, &volume)
-
-		// Write to DB
-		sqliteDB := sqliteOpen()
-		// Insert the database record
-		sqlStmt := `insert into exchanges (exchange, timestamp, ask, bid, volume, currencyCode) values ('` + exchange + `',` + timestamp + `, ` + ask + `,` + bid + `, ` + volume + `, '` + currencyCode + `');`
-		_, err = sqliteDB.Exec(sqlStmt)
-		if err != nil {
-			log.Warning("%q: %s\n", err, sqlStmt)
-			return
-		}
-		// Close the sqlite connection
-		sqliteDB.Close()
-	}
-}
-
-// SELECT function ifromnto sqlite
-func queryExchangeSQLite(exchange string, currencyCode string) (resp *APIStruct, err error) {
-
-	// If the exchange name is not there, ignore, otherwise run
-	if len(exchange) > 0 && len(currencyCode) > 0 {
-
-		// Write to DB
-		sqliteDB := sqliteOpen()
-
-		// Query for data
-		response := sqliteDB.QueryRow(`select exchange, ask, bid, ROUND((ask + bid) / 2, 8) as price,
-				volume as volume, datetime(timestamp, 'unixepoch') as timestamp, currencyCode
-				from exchanges
-				where currencyCode = ? and exchange = ? order by ID desc LIMIT 1;`, currencyCode, exchange)
-
-		tmp := &APIStruct{}
-		// Scan data into response
-		err := response.Scan(&tmp.Exchange, &tmp.Ask, &tmp.Bid, &tmp.Average, &tmp.Volume, &tmp.DateUpdated, &tmp.CurrencyCode)
-		if err != nil {
-			log.Warning("%q\n", err)
-			return nil, errors.New("No values found")
-		}
-		// Close the sqlite connection
-		sqliteDB.Close()
-
-		// return response
-		return tmp, nil
-	}
-	log.Warning("Nothing was queried!")
-	return nil, errors.New("Exchange or currency code empty")
-}
-
-// SELECT function ifromnto sqlite
-func queryExchangeCurrencyCodesSQLite(exchange string, url string) (resp []*string, err error) {
-
-	// If the exchange name is not there, ignore, otherwise run
-	if len(exchange) > 0 {
-
-		// Write to DB
-		sqliteDB := sqliteOpen()
-
-		// Query for data
-		response, err := sqliteDB.Query(`select DISTINCT currencyCode from exchanges where exchange = ?;`, exchange)
-		// Check if there are errors
-		if err != nil {
-			log.Error(err.Error())
-			return nil, err
-		}
-
-		// Scan the values into a string slice
-		for response.Next() {
-			var tmp string
-			response.Scan(&tmp)
-			tmp = url + tmp
-			resp = append(resp, &tmp)
-		}
-
-		// If anything was returned
-		if len(resp) == 0 {
-			return nil, errors.New("Exchange doesn't exist")
-		}
-
-		// return response
-		return resp, nil
-	}
-	log.Warning("Nothing was queried!")
-	return nil, errors.New("Exchange doesn't exists")
-}
-
-// SELECT function sqlite
-func queryListOfExchanges(url string) (resp []*string, err error) {
-
-	// Write to DB
-	sqliteDB := sqliteOpen()
-
-	// Query for data
-	response, err := sqliteDB.Query(`select DISTINCT exchange from exchanges;`)
-	// Check if there are errors
-	if err != nil {
-		log.Error(err.Error())
-		return nil, err
-	}
-
-	// Scan the values into a string slice
-	for response.Next() {
-		var tmp string
-		response.Scan(&tmp)
-		tmp = url + tmp
+// Turns a list of names into links below url
+func prefixURL(url string, names []string) (resp []*string) {
+	for i := range names {
+		tmp := url + names[i]
 		resp = append(resp, &tmp)
 	}
-
-	// If anything was returned
-	if len(resp) == 0 {
-		return nil, errors.New("No exchanges exist")
-	}
-
-	// return response
-	return resp, nil
+	return resp
 }
 
 // clean strings before inserting, to provide default values
@@ -663,22 +216,19 @@ func cleanStrings(timestamp *string, ask *string, bid *string, volume *string) {
 	}
 }
 
-// Waits for the minute to tick over
-func minuteTicker() *time.Ticker {
-	// Return new ticker that triggers on the minute
-	return time.NewTicker(time.Second * time.Duration(int(60*10)-time.Now().Second()))
-}
-
 // Configure logging
 func configLog() {
 
 	// Check if it is already open
 	logFile.Close()
 
-	// Configure logging
-	logFile, err := os.OpenFile(config.LogFile, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0666)
-	if err != nil {
-		log.Info("error opening file: %v", err)
+	// Configure logging, to stderr if no log file is set
+	logFile := os.Stderr
+	if len(config.LogFile) > 0 {
+		var err error
+		if logFile, err = os.OpenFile(config.LogFile, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0666); err != nil {
+			log.Info("error opening file: %v", err)
+		}
 	}
 
 	// For demo purposes, create two backend for os.Stderr.
@@ -693,122 +243,304 @@ func configLog() {
 	logging.SetBackend(loggingFileFormatter)
 }
 
-// Configure configs
-func configInit() {
+// Contents of the config file as last found valid. They are put back into
+// viper when a changed file is rejected, so that viper never holds values
+// that didn't pass the checks.
+var validConfigFile []byte
+
+// Reads the config file into viper and returns its contents
+func readConfigFile() ([]byte, error) {
+	data, err := os.ReadFile(viper.ConfigFileUsed())
+	if err != nil {
+		return nil, err
+	}
+	return data, viper.ReadConfig(bytes.NewReader(data))
+}
+
+// Configure configs. Returns every problem found with the config file, in
+// which case the config is left as it was.
+func configInit() []string {
 
-	// Config File
-	viper.SetConfigName("config") // no need to include file extension
-	viper.AddConfigPath(home)     // set the path of your config file
+	// Config File, either given with --config or found in the usual places
+	if info, err := os.Stat(configFlag); err == nil && !info.IsDir() {
+		viper.SetConfigFile(configFlag)
+	} else {
+		viper.SetConfigName("config") // no need to include file extension
+		if len(configFlag) > 0 {
+			viper.AddConfigPath(configFlag)
+		} else {
+			for _, dir := range configDirs() {
+				viper.AddConfigPath(dir)
+			}
+		}
+	}
+
+	// Let KBCT_* environment variables override the file
+	bindConfigEnv()
 
 	err := viper.ReadInConfig()
-	if err != nil {
+	if _, ok := err.(viper.ConfigFileNotFoundError); ok && len(configFlag) > 0 {
+		return []string{"No config file found in " + configFlag}
+	}
+	if _, ok := err.(viper.ConfigFileNotFoundError); ok {
 		log.Info("Config file not found... Error %s\n", err)
-	} else {
+		config = loadConfig()
+		return nil
+	}
+	if err != nil {
+		return []string{err.Error()}
+	}
 
-		// ========= CONFIG ================================================================
-		logFile := viper.GetString("config.logFile")
-		sqliteLocation := viper.GetString("config.sqliteLocation")
-		port := viper.GetString("config.port")
-		krakenurl := viper.GetString("exchanges.kraken.url")
-		krakenAPIKey := viper.GetString("exchanges.kraken.apiKey")
-		krakenAPISecret := viper.GetString("exchanges.kraken.apiSecret")
-		lunourl := viper.GetString("exchanges.luno.url")
-		bitstampurl := viper.GetString("exchanges.bitstamp.url")
-		bitfinexurl := viper.GetString("exchanges.bitfinex.url")
-		bitfinextickers := viper.GetString("exchanges.bitfinex.tickers")
-		bitsquareurl := viper.GetString("exchanges.bitsquare.url")
-		bitsquaretickers := viper.GetString("exchanges.bitsquare.tickers")
-		btccurl := viper.GetString("exchanges.btcc.url")
-		btcctickers := viper.GetString("exchanges.btcc.tickers")
-		okcoinurl := viper.GetString("exchanges.okcoin.url")
-		okcointickers := viper.GetString("exchanges.okcoin.tickers")
-		poloniexAPIKey := viper.GetString("exchanges.poloniex.apiKey")
-		poloniexAPISecret := viper.GetString("exchanges.poloniex.apiSecret")
-
-		// Kraken
-		kraken := KrakenConfig{
-			URL:       krakenurl,
-			APIKey:    krakenAPIKey,
-			APISecret: krakenAPISecret,
-		}
+	data, err := readConfigFile()
+	if err != nil {
+		return []string{err.Error()}
+	}
+	if problems := checkConfigFile(); len(problems) > 0 {
+		return problems
+	}
+	validConfigFile = data
+	config = loadConfig()
 
-		// Luno
-		luno := LunoConfig{
-			URL: lunourl,
-		}
+	// Monitor the config file for changes and reload
+	viper.WatchConfig()
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		reloadConfig()
+	})
 
-		// Bitstamp
-		bitstamp := BitstampConfig{
-			URL: bitstampurl,
-		}
+	return nil
+}
 
-		// Bitfinex
-		bitfinex := BitfinexConfig{
-			URL:     bitfinexurl,
-			Tickers: bitfinextickers,
-		}
+// Waits for the config file to stop changing, as editors and cp write it
+// in several steps and the watcher fires on the first of them
+func waitForConfigFile() {
+	data, err := os.ReadFile(viper.ConfigFileUsed())
+	for i := 0; i < configSettleChecks && err == nil; i++ {
+		time.Sleep(configSettleDelay)
 
-		// Bitsquare
-		bitsquare := BitsquareConfig{
-			URL:     bitsquareurl,
-			Tickers: bitsquaretickers,
+		var again []byte
+		again, err = os.ReadFile(viper.ConfigFileUsed())
+		if bytes.Equal(again, data) {
+			return
 		}
+		data = again
+	}
+}
 
-		// BTCChina
-		btcc := BtccConfig{
-			URL:     btccurl,
-			Tickers: btcctickers,
-		}
+// How long the config file must stay the same before a change is applied,
+// and how many times to wait for it
+const (
+	configSettleDelay  = 200 * time.Millisecond
+	configSettleChecks = 10
+)
 
-		// OKCoin
-		okcoin := OKCoinConfig{
-			URL:     okcoinurl,
-			Tickers: okcointickers,
+// Applies a changed config file, returning whether it was applied. A file
+// that doesn't hold up is logged and the previous config kept.
+func reloadConfig() bool {
+
+	// The watcher has already read the file, but doesn't say if it failed
+	waitForConfigFile()
+	data, err := readConfigFile()
+	var problems []string
+	if err != nil {
+		problems = []string{err.Error()}
+	} else {
+		problems = checkConfigFile()
+	}
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			log.Error(problem)
 		}
+		log.Warning("Config file changed but is invalid, keeping the previous config")
 
-		// Poloniex
-		poloniex := PoloniexConfig{
-			APIKey:    poloniexAPIKey,
-			APISecret: poloniexAPISecret,
+		// Put the previous values back for everything reading viper
+		if err := viper.ReadConfig(bytes.NewReader(validConfigFile)); err != nil {
+			log.Error(err.Error())
 		}
+		return false
+	}
+	validConfigFile = data
+
+	// Re-configure config
+	config = loadConfig()
 
-		// Main Config
-		config = Config{
-			LogFile:        logFile,
-			SqliteLocation: sqliteLocation,
-			Port:           port,
-			Kraken:         kraken,
-			Luno:           luno,
-			Bitstamp:       bitstamp,
-			Bitfinex:       bitfinex,
-			Bitsquare:      bitsquare,
-			BTCC:           btcc,
-			OKCoin:         okcoin,
-			Poloniex:       poloniex,
+	// Print out what the new config is
+	log.Info("Config: %s", redactedConfig(config))
+
+	// Re-configure logging
+	configLog()
+
+	// Pick up any changed alert rules
+	if store != nil {
+		if err := syncConfigAlerts(config.Alerts); err != nil {
+			log.Warning("%q\n", err)
 		}
 	}
 
-	// Monitor the config file for changes and reload
-	viper.WatchConfig()
-	viper.OnConfigChange(func(e fsnotify.Event) {
+	// Pick up any changed worker count and polling intervals
+	if tickerScheduler != nil {
+		tickerScheduler.resize(config.Workers)
+		tickerScheduler.reschedule(registeredExchanges())
+	}
 
-		// Re-configure config
-		configInit()
+	log.Info("Config file changed")
+	return true
+}
 
-		// Print out what the new config is
-		log.Info("Log file %+v", config)
+// Builds the config from the config file, filling in the defaults of
+// anything that isn't set
+func loadConfig() Config {
 
-		// Re-configure logging
-		configLog()
+	// Secrets that can't be read are reported by checkConfigFile
+	secret := func(key string) string {
+		value, _ := secretValue(strings.ToLower(key))
+		return value
+	}
 
-		log.Info("Config file changed")
-	})
+	// ========= CONFIG ================================================================
+	logFile := viper.GetString("config.logFile")
+	sqliteLocation, err := resolveSqliteLocation(viper.GetString("config.sqliteLocation"), viper.ConfigFileUsed())
+	if err != nil {
+		log.Warning(err.Error())
+	}
+	port := viper.GetString("config.port")
+	databaseDriver := viper.GetString("database.driver")
+	databaseDSN := secret("database.dsn")
+	aliases := loadAliases(viper.GetStringMapString("aliases"))
+	workers := viper.GetInt("config.workers")
+	fetchTimeout := viper.GetDuration("config.fetchTimeout")
+	interval := viper.GetDuration("config.interval")
+	requestTimeout := viper.GetDuration("config.requestTimeout")
+	retries := viper.GetInt("config.retries")
+	retryBaseDelay := viper.GetDuration("config.retryBaseDelay")
+	retryMaxDelay := viper.GetDuration("config.retryMaxDelay")
+	breakerThreshold := viper.GetInt("config.breakerThreshold")
+	breakerCooldown := viper.GetDuration("config.breakerCooldown")
+	staleAfter := viper.GetFloat64("config.staleAfter")
+	alertSecret := secret("config.alertSecret")
+	alertToken := secret("config.alertToken")
+	analyticsInterval := viper.GetDuration("analytics.interval")
+	referenceExchange := viper.GetString("analytics.referenceExchange")
+	referencePair := viper.GetString("analytics.referencePair")
+	fiatBase := strings.ToUpper(viper.GetString("analytics.fiatBase"))
+	fiatRates := make(map[string]float64)
+	for code := range viper.GetStringMap("analytics.fiatRates") {
+		fiatRates[strings.ToUpper(code)] = viper.GetFloat64("analytics.fiatRates." + code)
+	}
+
+	// Default to a handful of workers and a sane fetch deadline
+	if len(port) == 0 {
+		port = "9091"
+	}
+	if workers <= 0 {
+		workers = 4
+	}
+	if fetchTimeout <= 0 {
+		fetchTimeout = 30 * time.Second
+	}
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+	if requestTimeout <= 0 {
+		requestTimeout = 10 * time.Second
+	}
+	if !viper.IsSet("config.retries") {
+		retries = 3
+	}
+	if retryBaseDelay <= 0 {
+		retryBaseDelay = 500 * time.Millisecond
+	}
+	if retryMaxDelay <= 0 {
+		retryMaxDelay = 30 * time.Second
+	}
+	if breakerThreshold <= 0 {
+		breakerThreshold = 5
+	}
+	if breakerCooldown <= 0 {
+		breakerCooldown = 5 * time.Minute
+	}
+	if staleAfter <= 0 {
+		staleAfter = 3
+	}
+	if analyticsInterval <= 0 {
+		analyticsInterval = 5 * time.Minute
+	}
+	if len(referenceExchange) == 0 {
+		referenceExchange = "Bitstamp"
+	}
+	if len(referencePair) == 0 {
+		referencePair = "BTC-USD"
+	}
+	if len(fiatBase) == 0 {
+		fiatBase = "USD"
+	}
+
+	// Every registered exchange reads its own [exchanges.*] section.
+	// Exchanges are polled unless their section sets enabled = false.
+	exchanges := make(map[string]ExchangeConfig)
+	for _, exchange := range registeredExchanges() {
+		section := "exchanges." + exchange.Key() + "."
+		enabled := !viper.IsSet(section+"enabled") || viper.GetBool(section+"enabled")
+		exchanges[exchange.Key()] = ExchangeConfig{
+			Enabled:   enabled,
+			URL:       viper.GetString(section + "url"),
+			APIKey:    secret(section + "apiKey"),
+			APISecret: secret(section + "apiSecret"),
+			Tickers:   viper.GetString(section + "tickers"),
+			Interval:  viper.GetDuration(section + "interval"),
+		}
+	}
+
+	// Main Config
+	return Config{
+		LogFile:        logFile,
+		SqliteLocation: sqliteLocation,
+		Port:           port,
+		Database: DatabaseConfig{
+			Driver: databaseDriver,
+			DSN:    databaseDSN,
+		},
+		Workers:          workers,
+		FetchTimeout:     fetchTimeout,
+		Interval:         interval,
+		RequestTimeout:   requestTimeout,
+		Retries:          retries,
+		RetryBaseDelay:   retryBaseDelay,
+		RetryMaxDelay:    retryMaxDelay,
+		BreakerThreshold: breakerThreshold,
+		BreakerCooldown:  breakerCooldown,
+		StaleAfter:       staleAfter,
+		AlertSecret:      alertSecret,
+		AlertToken:       alertToken,
+		Alerts:           loadConfigAlerts(alertSecret),
+		Exchanges:        exchanges,
+		Aliases:          aliases,
+		Analytics: AnalyticsConfig{
+			Interval:          analyticsInterval,
+			ReferenceExchange: referenceExchange,
+			ReferencePair:     referencePair,
+			FiatRatesURL:      viper.GetString("analytics.fiatRatesURL"),
+			FiatBase:          fiatBase,
+			FiatRates:         fiatRates,
+		},
+	}
 }
 
 func main() {
 
-	// Initialise config file and settings
-	configInit()
+	// Run everything unless told otherwise
+	command, args := "run", parseGlobalFlags(os.Args[1:])
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	// Initialise config file and settings, refusing to run on a broken one
+	problems := configInit()
+	if len(problems) > 0 && command != "config" {
+		for _, problem := range problems {
+			fmt.Fprintln(os.Stderr, problem)
+		}
+		os.Exit(1)
+	}
 
 	// Configure logging
 	configLog()
@@ -816,23 +548,52 @@ func main() {
 	// don't forget to close the log file
 	defer logFile.Close()
 
-	// Setup Sqlite DB
-	setupSQLiteDB()
-
-	// Start bitcoin ticker
-	go bitcoinPrices()
-
-	// Notify log that we are up and running
-	log.Info("started kyco.bitcoin.currency.tickers")
-
-	// Setup API
-	router := mux.NewRouter()
-
-	// Setup Route
-	router.HandleFunc("/{exchange}/{currencyCode}", get_exchange_rate).Methods("GET")
-	router.HandleFunc("/{exchange}", show_exchange_methods).Methods("GET")
-	router.HandleFunc("/", showExchanges).Methods("GET")
-
-	// Create listen and serve
-	http.ListenAndServe(":"+config.Port, router)
+	switch command {
+	case "run":
+		setupStore()
+		defer store.Close()
+		startIngest()
+		serveAPI()
+
+	case "serve":
+		setupStore()
+		defer store.Close()
+		startTail()
+		serveAPI()
+
+	case "ingest":
+		setupStore()
+		defer store.Close()
+		startIngest()
+		select {}
+
+	case "fetch":
+		fetchCommand(args)
+
+	case "query":
+		setupStore()
+		defer store.Close()
+		queryCommand(args)
+
+	case "migrate":
+		openDatabase()
+		defer store.Close()
+		migrateCommand()
+
+	case "export":
+		setupStore()
+		defer store.Close()
+		exportCommand(args)
+
+	case "config":
+		configCommand(args, problems)
+
+	case "help", "-h", "-help", "--help":
+		usage(os.Stdout)
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", command)
+		usage(os.Stderr)
+		os.Exit(2)
+	}
 }
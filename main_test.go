@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// Loads a valid config file as configInit does
+func loadTestConfig(t *testing.T, contents string) {
+	t.Helper()
+
+	if problems := checkTestConfig(t, contents); len(problems) > 0 {
+		t.Fatal(problems)
+	}
+	validConfigFile = []byte(contents)
+	config = loadConfig()
+}
+
+func TestReloadConfigKeepsPreviousConfig(t *testing.T) {
+	cases := []struct {
+		name     string
+		contents string
+	}{
+		{"empty", ""},
+		{"partial", "[config]\nport = \"9092\"\n"},
+		{"unparsable", "[config\nport = "},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			loadTestConfig(t, testConfigFile)
+
+			if err := os.WriteFile(viper.ConfigFileUsed(), []byte(c.contents), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if reloadConfig() {
+				t.Fatal("expected the reload to be rejected")
+			}
+
+			if config.Port != "9091" || config.Exchanges["luno"].URL != "https://api.mybitx.com/api/1/tickers" {
+				t.Fatalf("config changed to %+v", config)
+			}
+			if port := viper.GetString("config.port"); port != "9091" {
+				t.Fatalf("viper holds port %q", port)
+			}
+		})
+	}
+}
+
+func TestReloadConfigAppliesValidChange(t *testing.T) {
+	loadTestConfig(t, testConfigFile)
+
+	changed := strings.Replace(testConfigFile, `interval = "10m"`, `interval = "5m"`, 1)
+	if err := os.WriteFile(viper.ConfigFileUsed(), []byte(changed), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if !reloadConfig() {
+		t.Fatal("expected the reload to be applied")
+	}
+	if config.Interval.String() != "5m0s" {
+		t.Fatalf("expected an interval of 5m, got %s", config.Interval)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prefix of every metric
+const metricsNamespace = "kbct"
+
+var (
+	quoteBid = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "quote_bid",
+		Help:      "Latest bid price per exchange and pair.",
+	}, []string{"exchange", "pair"})
+
+	quoteAsk = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "quote_ask",
+		Help:      "Latest ask price per exchange and pair.",
+	}, []string{"exchange", "pair"})
+
+	quoteMid = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "quote_mid",
+		Help:      "Latest mid price per exchange and pair.",
+	}, []string{"exchange", "pair"})
+
+	fetchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "fetch_duration_seconds",
+		Help:      "Time taken to fetch the quotes of an exchange, retries included.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"exchange"})
+
+	fetchErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "fetch_errors_total",
+		Help:      "Failed fetches per exchange and type of error.",
+	}, []string{"exchange", "type"})
+
+	rowsInserted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "rows_inserted_total",
+		Help:      "Quotes written to the exchanges table per exchange.",
+	}, []string{"exchange"})
+
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "db_size_bytes",
+		Help:      "Size of the database.",
+	}, databaseSize)
+
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests per route, method and status code.",
+	}, []string{"route", "method", "code"})
+)
+
+// Serves the metrics in the Prometheus exposition format
+var metricsHandler = promhttp.Handler()
+
+// Records the outcome of fetching the quotes of an exchange
+func recordFetch(exchange string, duration time.Duration, err error) {
+	fetchDuration.WithLabelValues(exchange).Observe(duration.Seconds())
+	if err != nil {
+		fetchErrors.WithLabelValues(exchange, fetchErrorType(err)).Inc()
+	}
+}
+
+// Sorts fetch errors into a few broad types, to keep the number of
+// series down
+func fetchErrorType(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled):
+		return "timeout"
+	case errors.As(err, &syntaxErr) || errors.As(err, &typeErr):
+		return "decode"
+	case strings.HasPrefix(err.Error(), "Empty response"):
+		return "request"
+	}
+	return "other"
+}
+
+// Records the rows written for a poll and their prices
+func recordRows(exchange string, rows []*APIStruct) {
+	rowsInserted.WithLabelValues(exchange).Add(float64(len(rows)))
+
+	for _, row := range rows {
+		pair := row.CurrencyCode
+		if len(row.Base) > 0 && len(row.Quote) > 0 {
+			pair = row.Base + "-" + row.Quote
+		}
+		quoteBid.WithLabelValues(row.Exchange, pair).Set(row.Bid)
+		quoteAsk.WithLabelValues(row.Exchange, pair).Set(row.Ask)
+		quoteMid.WithLabelValues(row.Exchange, pair).Set(row.Average)
+	}
+}
+
+// Reads the size of the database on every scrape
+func databaseSize() float64 {
+	if store == nil {
+		return 0
+	}
+	size, err := store.Size()
+	if err != nil {
+		log.Warning("%q\n", err)
+		return 0
+	}
+	return float64(size)
+}
+
+// Counts the requests of every route by its template, eg.
+// /{exchange}/{currencyCode}, rather than by path
+func instrumentRoutes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		route := "unknown"
+		if current := mux.CurrentRoute(req); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		counter := httpRequests.MustCurryWith(prometheus.Labels{"route": route})
+		promhttp.InstrumentHandlerCounter(counter, next).ServeHTTP(w, req)
+	})
+}
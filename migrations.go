@@ -0,0 +1,297 @@
+package main
+
+import (
+	"database/sql"
+	"time"
+)
+
+// A numbered change to the schema. Each dialect has its own statements,
+// a dialect without any statements skips them. Data changes that can't
+// be written in SQL go in run, which is called after the statements.
+//
+// MySQL commits schema changes on its own, so when run fails there the
+// statements stay applied while the migration isn't recorded. Migrations
+// with statements that can't be repeated set applied, which tells whether
+// they already took effect, and a run that can safely be repeated.
+type migration struct {
+	version    int
+	name       string
+	statements map[string][]string
+	applied    func(tx *sql.Tx, d sqlDialect) (bool, error)
+	run        func(tx *sql.Tx, d sqlDialect) error
+}
+
+// Every migration, oldest first. Migrations are only ever added to the
+// end of this list, never changed once released.
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create exchanges table",
+		statements: map[string][]string{
+			"sqlite": {
+				`create table if not exists exchanges (id integer not null primary key, exchange text, timestamp real, ask real, bid real, volume real default 0, currencyCode text);`,
+			},
+			"postgres": {
+				`create table if not exists exchanges (id bigserial primary key, exchange text, timestamp double precision, ask double precision, bid double precision, volume double precision default 0, currencyCode text);`,
+			},
+			// The MySQL table has always been created with its indexes
+			"mysql": {
+				`create table if not exists exchanges (
+					id bigint not null auto_increment primary key,
+					exchange varchar(64),
+					timestamp double,
+					ask double,
+					bid double,
+					volume double default 0,
+					currencyCode varchar(32),
+					index exchanges_latest (exchange, currencyCode, id),
+					index exchanges_timestamp (timestamp)
+				) engine=InnoDB default charset=utf8mb4;`,
+			},
+		},
+	},
+	{
+		version: 2,
+		name:    "index latest quote lookups",
+		statements: map[string][]string{
+			"sqlite":   {`create index if not exists exchanges_latest on exchanges (exchange, currencyCode, id);`},
+			"postgres": {`create index if not exists exchanges_latest on exchanges (exchange, currencyCode, id);`},
+		},
+	},
+	{
+		version: 3,
+		name:    "index timestamps",
+		statements: map[string][]string{
+			"sqlite":   {`create index if not exists exchanges_timestamp on exchanges (timestamp);`},
+			"postgres": {`create index if not exists exchanges_timestamp on exchanges (timestamp);`},
+		},
+	},
+	{
+		version: 4,
+		name:    "add base and quote columns",
+		statements: map[string][]string{
+			"sqlite": {
+				`alter table exchanges add column base text;`,
+				`alter table exchanges add column quote text;`,
+				`create index if not exists exchanges_pair on exchanges (exchange, base, quote, id);`,
+			},
+			"postgres": {
+				`alter table exchanges add column if not exists base text;`,
+				`alter table exchanges add column if not exists quote text;`,
+				`create index if not exists exchanges_pair on exchanges (exchange, base, quote, id);`,
+			},
+			"mysql": {
+				`alter table exchanges add column base varchar(16), add column quote varchar(16), add index exchanges_pair (exchange, base, quote, id);`,
+			},
+		},
+		applied: func(tx *sql.Tx, d sqlDialect) (bool, error) {
+			return hasColumn(tx, d, "exchanges", "base")
+		},
+		run: backfillPairs,
+	},
+	{
+		version: 5,
+		name:    "add arbitrage and premium history",
+		statements: map[string][]string{
+			"sqlite": {
+				`create table if not exists arbitrage_history (id integer not null primary key, timestamp real, base text, quote text, buyExchange text, buyPrice real, sellExchange text, sellPrice real, spread real, exchangeCount integer);`,
+				`create index if not exists arbitrage_history_pair on arbitrage_history (base, quote, timestamp);`,
+				`create table if not exists premium_history (id integer not null primary key, timestamp real, exchange text, base text, quote text, price real, referenceExchange text, referenceBase text, referenceQuote text, referencePrice real, fiatRate real, premium real);`,
+				`create index if not exists premium_history_pair on premium_history (exchange, base, quote, timestamp);`,
+			},
+			"postgres": {
+				`create table if not exists arbitrage_history (id bigserial primary key, timestamp double precision, base text, quote text, buyExchange text, buyPrice double precision, sellExchange text, sellPrice double precision, spread double precision, exchangeCount integer);`,
+				`create index if not exists arbitrage_history_pair on arbitrage_history (base, quote, timestamp);`,
+				`create table if not exists premium_history (id bigserial primary key, timestamp double precision, exchange text, base text, quote text, price double precision, referenceExchange text, referenceBase text, referenceQuote text, referencePrice double precision, fiatRate double precision, premium double precision);`,
+				`create index if not exists premium_history_pair on premium_history (exchange, base, quote, timestamp);`,
+			},
+			"mysql": {
+				`create table if not exists arbitrage_history (
+					id bigint not null auto_increment primary key,
+					timestamp double,
+					base varchar(16),
+					quote varchar(16),
+					buyExchange varchar(64),
+					buyPrice double,
+					sellExchange varchar(64),
+					sellPrice double,
+					spread double,
+					exchangeCount int,
+					index arbitrage_history_pair (base, quote, timestamp)
+				) engine=InnoDB default charset=utf8mb4;`,
+				`create table if not exists premium_history (
+					id bigint not null auto_increment primary key,
+					timestamp double,
+					exchange varchar(64),
+					base varchar(16),
+					quote varchar(16),
+					price double,
+					referenceExchange varchar(64),
+					referenceBase varchar(16),
+					referenceQuote varchar(16),
+					referencePrice double,
+					fiatRate double,
+					premium double,
+					index premium_history_pair (exchange, base, quote, timestamp)
+				) engine=InnoDB default charset=utf8mb4;`,
+			},
+		},
+	},
+	{
+		version: 6,
+		name:    "add alert rules",
+		statements: map[string][]string{
+			"sqlite": {
+				`create table if not exists alert_rules (id integer not null primary key, name text not null unique, source text, kind text, exchange text, base text, quote text, operator text, threshold real, windowSeconds integer, indexPair text, webhook text, secret text, enabled integer default 1);`,
+			},
+			"postgres": {
+				`create table if not exists alert_rules (id bigserial primary key, name text not null unique, source text, kind text, exchange text, base text, quote text, operator text, threshold double precision, windowSeconds bigint, indexPair text, webhook text, secret text, enabled integer default 1);`,
+			},
+			"mysql": {
+				`create table if not exists alert_rules (
+					id bigint not null auto_increment primary key,
+					name varchar(128) not null unique,
+					source varchar(16),
+					kind varchar(16),
+					exchange varchar(64),
+					base varchar(16),
+					quote varchar(16),
+					operator varchar(16),
+					threshold double,
+					windowSeconds bigint,
+					indexPair varchar(32),
+					webhook text,
+					secret text,
+					enabled int default 1
+				) engine=InnoDB default charset=utf8mb4;`,
+			},
+		},
+	},
+}
+
+// Whether a table has a column
+func hasColumn(tx *sql.Tx, d sqlDialect, table string, column string) (bool, error) {
+	var count int
+	err := tx.QueryRow(d.rebind(d.columnCount), table, column).Scan(&count)
+	return count > 0, err
+}
+
+// Fills in the pair of every row stored before pairs were recorded
+func backfillPairs(tx *sql.Tx, d sqlDialect) error {
+	type market struct {
+		exchange     string
+		currencyCode string
+	}
+
+	// Read the markets first, the update can't run while rows are open
+	rows, err := tx.Query(`select DISTINCT exchange, currencyCode from exchanges where base is null;`)
+	if err != nil {
+		return err
+	}
+	var markets []market
+	for rows.Next() {
+		var m market
+		if err := rows.Scan(&m.exchange, &m.currencyCode); err != nil {
+			rows.Close()
+			return err
+		}
+		markets = append(markets, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range markets {
+		pair := legacyPair(m.exchange, m.currencyCode)
+		if _, err := tx.Exec(d.rebind(`update exchanges set base = ?, quote = ? where exchange = ? and currencyCode = ? and base is null;`), pair.Base, pair.Quote, m.exchange, m.currencyCode); err != nil {
+			return err
+		}
+		log.Infof("Backfilled %s %s as %s", m.exchange, m.currencyCode, pair)
+	}
+	return nil
+}
+
+// Returns the version of the schema, 0 if no migration has run
+func (s *sqlStore) SchemaVersion() (int, error) {
+	if err := s.createSchemaVersion(); err != nil {
+		return 0, err
+	}
+
+	var version int
+	err := s.db.QueryRow(`select COALESCE(MAX(version), 0) from schema_version;`).Scan(&version)
+	return version, err
+}
+
+// Creates the table keeping track of the applied migrations
+func (s *sqlStore) createSchemaVersion() error {
+	_, err := s.db.Exec(`create table if not exists schema_version (version integer not null primary key, name varchar(255), applied_at bigint);`)
+	return err
+}
+
+// Runs every migration newer than the current schema version, each in
+// its own transaction. Returns the number of migrations applied.
+func (s *sqlStore) Migrate() (int, error) {
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		if err := s.migrate(m); err != nil {
+			log.Errorf("Migration %d (%s) failed: %s", m.version, m.name, err.Error())
+			return applied, err
+		}
+
+		log.Infof("Applied migration %d (%s)", m.version, m.name)
+		applied++
+	}
+	return applied, nil
+}
+
+// Applies a single migration and records it in schema_version
+func (s *sqlStore) migrate(m migration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// Skip the statements if a failed attempt already applied them
+	done := false
+	if m.applied != nil {
+		if done, err = m.applied(tx, s.dialect); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	var statements []string
+	if !done {
+		statements = m.statements[s.dialect.name]
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if m.run != nil {
+		if err := m.run(tx, s.dialect); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(s.dialect.rebind(`insert into schema_version (version, name, applied_at) values (?, ?, ?);`), m.version, m.name, time.Now().Unix()); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
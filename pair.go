@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+// Pair is a trading pair. Prices are the amount of Quote paid for one
+// unit of Base, so BTC-ZAR is the price of a bitcoin in rand.
+type Pair struct {
+	Base  string
+	Quote string
+}
+
+// Formats the pair the way the API expects it, eg. BTC-ZAR
+func (p Pair) String() string {
+	return p.Base + "-" + p.Quote
+}
+
+// Reports whether both sides of the pair are known
+func (p Pair) Valid() bool {
+	return len(p.Base) > 0 && len(p.Quote) > 0
+}
+
+// Parses a pair as given to the API, eg. BTC-ZAR
+func parsePair(symbol string) (Pair, error) {
+	parts := strings.Split(symbol, "-")
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return Pair{}, errors.New("Pair must look like BASE-QUOTE")
+	}
+	return Pair{Base: canonicalAsset(parts[0]), Quote: canonicalAsset(parts[1])}, nil
+}
+
+// Built-in aliases from exchange-native asset codes to the ISO 4217 or
+// common crypto ticker used in the database. The [aliases] section of the
+// config file adds to and overrides these.
+var defaultAliases = map[string]string{
+	"XBT":  "BTC",
+	"XXBT": "BTC",
+	"XETH": "ETH",
+	"XETC": "ETC",
+	"XLTC": "LTC",
+	"XXRP": "XRP",
+	"XXMR": "XMR",
+	"XZEC": "ZEC",
+	"XXDG": "DOGE",
+	"XDG":  "DOGE",
+	"DSH":  "DASH",
+	"ZUSD": "USD",
+	"ZEUR": "EUR",
+	"ZGBP": "GBP",
+	"ZJPY": "JPY",
+	"ZCAD": "CAD",
+}
+
+// Maps an exchange-native asset code onto the code used in the database
+func canonicalAsset(code string) string {
+	code = strings.ToUpper(code)
+	if alias, ok := config.Aliases[code]; ok {
+		return alias
+	}
+	if alias, ok := defaultAliases[code]; ok {
+		return alias
+	}
+	return code
+}
+
+// Reads the [aliases] section of the config file. Keys and values are
+// upper cased, as viper lower cases keys.
+func loadAliases(aliases map[string]string) map[string]string {
+	resp := make(map[string]string)
+	for code, alias := range aliases {
+		resp[strings.ToUpper(code)] = strings.ToUpper(alias)
+	}
+	return resp
+}
+
+// Splits a symbol made up of two three letter codes, eg. btcusd
+func splitFixedSymbol(symbol string) (Pair, error) {
+	if len(symbol) != 6 {
+		return Pair{}, errors.New("Unknown symbol " + symbol)
+	}
+	return Pair{Base: canonicalAsset(symbol[:3]), Quote: canonicalAsset(symbol[3:])}, nil
+}
+
+// Splits a symbol whose codes are separated by sep, eg. btc_usd
+func splitSeparatedSymbol(symbol string, sep string) (Pair, error) {
+	parts := strings.Split(symbol, sep)
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return Pair{}, errors.New("Unknown symbol " + symbol)
+	}
+	return Pair{Base: canonicalAsset(parts[0]), Quote: canonicalAsset(parts[1])}, nil
+}
+
+// Fiat currencies that bitcoin was quoted in before pairs were stored
+var fiatCurrencies = map[string]bool{
+	"USD": true, "EUR": true, "GBP": true, "ZAR": true, "NGN": true,
+	"MYR": true, "IDR": true, "CNY": true, "JPY": true, "CAD": true,
+	"AUD": true, "USDT": true, "UGX": true, "ZMW": true, "KES": true,
+}
+
+// Works out the pair of a row stored before pairs were recorded, from the
+// currency code that formatCurrencyString left behind
+func legacyPair(exchange string, currencyCode string) Pair {
+	code := strings.ToUpper(currencyCode)
+
+	switch {
+	// Poloniex keys are stored as is, and list the quote first
+	case strings.Contains(code, "_"):
+		parts := strings.SplitN(code, "_", 2)
+		return Pair{Base: canonicalAsset(parts[1]), Quote: canonicalAsset(parts[0])}
+	// Luno ETHXBT lost its base when it was stored
+	case exchange == "Luno" && code == "XBT":
+		return Pair{Base: "ETH", Quote: "BTC"}
+	// The rest of Luno's rows are XBT priced in what is left of the code
+	case exchange == "Luno":
+		return Pair{Base: "BTC", Quote: canonicalAsset(code)}
+	// Bitcoin priced in a fiat currency
+	case fiatCurrencies[code]:
+		return Pair{Base: "BTC", Quote: code}
+	// Pairs without bitcoin were left whole, eg. LTCUSD
+	case len(code) == 6:
+		return Pair{Base: canonicalAsset(code[:3]), Quote: canonicalAsset(code[3:])}
+	}
+
+	// Anything else is an altcoin priced in bitcoin
+	return Pair{Base: canonicalAsset(code), Quote: "BTC"}
+}
+
+// Selects the rows of a single exchange, either by pair or by the legacy
+// currency code
+type Market struct {
+	Exchange     string
+	CurrencyCode string
+	Pair         Pair
+}
+
+// Builds a market from the exchange and symbol of an API call. Symbols
+// containing a dash, eg. BTC-ZAR, are pairs, anything else is a
+// currency code.
+func parseMarket(exchange string, symbol string) (Market, error) {
+	if len(exchange) == 0 || len(symbol) == 0 {
+		return Market{}, errors.New("Exchange or currency code empty")
+	}
+
+	if !strings.Contains(symbol, "-") {
+		return Market{Exchange: exchange, CurrencyCode: symbol}, nil
+	}
+
+	pair, err := parsePair(symbol)
+	if err != nil {
+		return Market{}, err
+	}
+	return Market{Exchange: exchange, Pair: pair}, nil
+}
+
+// Returns the SQL condition and arguments selecting the market
+func (m Market) where() (string, []interface{}) {
+	if m.Pair.Valid() {
+		return `exchange = ? and base = ? and quote = ?`, []interface{}{m.Exchange, m.Pair.Base, m.Pair.Quote}
+	}
+	return `currencyCode = ? and exchange = ?`, []interface{}{m.CurrencyCode, m.Exchange}
+}
+
+// Names the market in log messages
+func (m Market) String() string {
+	if m.Pair.Valid() {
+		return m.Exchange + " -> " + m.Pair.String()
+	}
+	return m.Exchange + " -> " + m.CurrencyCode
+}
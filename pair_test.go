@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestLegacyPair(t *testing.T) {
+	cases := []struct {
+		exchange     string
+		currencyCode string
+		want         Pair
+	}{
+		{"Luno", "ZAR", Pair{Base: "BTC", Quote: "ZAR"}},
+		{"Luno", "UGX", Pair{Base: "BTC", Quote: "UGX"}},
+		{"Luno", "ZMW", Pair{Base: "BTC", Quote: "ZMW"}},
+		{"Luno", "XBT", Pair{Base: "ETH", Quote: "BTC"}},
+		{"Kraken", "EUR", Pair{Base: "BTC", Quote: "EUR"}},
+		{"Kraken", "DASH", Pair{Base: "DASH", Quote: "BTC"}},
+		{"Bitstamp", "USD", Pair{Base: "BTC", Quote: "USD"}},
+		{"Bitfinex", "ETH", Pair{Base: "ETH", Quote: "BTC"}},
+		{"Bitfinex", "LTCUSD", Pair{Base: "LTC", Quote: "USD"}},
+		{"Poloniex", "BTC_ETH", Pair{Base: "ETH", Quote: "BTC"}},
+		{"Poloniex", "usdt_btc", Pair{Base: "BTC", Quote: "USDT"}},
+	}
+
+	for _, c := range cases {
+		if got := legacyPair(c.exchange, c.currencyCode); got != c.want {
+			t.Errorf("legacyPair(%q, %q) = %s, want %s", c.exchange, c.currencyCode, got, c.want)
+		}
+	}
+
+	// Only Luno's ETH rows lost their base
+	if got := legacyPair("Kraken", "XBT"); got.Base == "ETH" {
+		t.Errorf("legacyPair(Kraken, XBT) = %s, only Luno's XBT rows are ETH-BTC", got)
+	}
+}
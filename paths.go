@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Name of the directories holding the config file and the data
+const appName = "kyco.bitcoin.currency.tickers"
+
+// Set by the --config and --data-dir flags
+var (
+	configFlag  string
+	dataDirFlag string
+)
+
+// Parses the flags given before the command, eg.
+// --config /etc/kyco.bitcoin.currency.tickers/config.toml serve,
+// returning the command and its arguments
+func parseGlobalFlags(args []string) []string {
+	flags := flag.NewFlagSet(appName, flag.ExitOnError)
+	flags.StringVar(&configFlag, "config", "", "config file, or directory holding config.toml")
+	flags.StringVar(&dataDirFlag, "data-dir", "", "directory holding the sqlite database")
+	flags.Usage = func() { usage(os.Stderr) }
+	flags.Parse(args)
+	return flags.Args()
+}
+
+// Directories searched for config.toml when --config isn't given, in
+// order: systemd's ConfigurationDirectory, $XDG_CONFIG_HOME (by default
+// ~/.config), $XDG_CONFIG_DIRS (by default /etc/xdg) and /etc
+func configDirs() []string {
+	var dirs []string
+	if dir := os.Getenv("CONFIGURATION_DIRECTORY"); len(dir) > 0 {
+		dirs = append(dirs, dir)
+	}
+
+	if dir := os.Getenv("XDG_CONFIG_HOME"); len(dir) > 0 {
+		dirs = append(dirs, filepath.Join(dir, appName))
+	} else if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".config", appName))
+	}
+
+	xdgDirs := os.Getenv("XDG_CONFIG_DIRS")
+	if len(xdgDirs) == 0 {
+		xdgDirs = "/etc/xdg"
+	}
+	for _, dir := range filepath.SplitList(xdgDirs) {
+		dirs = append(dirs, filepath.Join(dir, appName))
+	}
+
+	return append(dirs, filepath.Join("/etc", appName))
+}
+
+// Describes where the config file was looked for
+func configSearchPath() string {
+	if len(configFlag) > 0 {
+		return configFlag
+	}
+	return strings.Join(configDirs(), ", ")
+}
+
+// Directory of the sqlite database, in order: --data-dir, systemd's
+// StateDirectory, the directory of the config file if it already holds a
+// data.db from older versions, and $XDG_DATA_HOME (by default
+// ~/.local/share)
+func dataDir(configFile string) (string, error) {
+	if len(dataDirFlag) > 0 {
+		return dataDirFlag, nil
+	}
+	if dir := os.Getenv("STATE_DIRECTORY"); len(dir) > 0 {
+		return filepath.SplitList(dir)[0], nil
+	}
+
+	if len(configFile) > 0 {
+		legacy := filepath.Dir(configFile)
+		if _, err := os.Stat(filepath.Join(legacy, "data.db")); err == nil {
+			return legacy, nil
+		}
+	}
+
+	if dir := os.Getenv("XDG_DATA_HOME"); len(dir) > 0 {
+		return filepath.Join(dir, appName), nil
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "share", appName), nil
+	}
+	return "", errors.New("No data directory, set --data-dir, $XDG_DATA_HOME or $HOME")
+}
+
+// Works out the path of the sqlite database from the sqliteLocation
+// setting. Relative locations are inside the data directory, and an
+// empty one is data.db in it.
+func resolveSqliteLocation(location string, configFile string) (string, error) {
+	if filepath.IsAbs(location) {
+		return location, nil
+	}
+
+	dir, err := dataDir(configFile)
+	if err != nil {
+		return "", err
+	}
+	if len(location) == 0 {
+		location = "data.db"
+	}
+
+	path, err := filepath.Abs(filepath.Join(dir, location))
+	if err != nil {
+		return "", fmt.Errorf("sqliteLocation: %s", err.Error())
+	}
+	return path, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Initialises various bitcoin price tickers, each on its own interval
+func bitcoinPrices() {
+	tickerScheduler = newScheduler(config.Workers)
+	tickerScheduler.reschedule(registeredExchanges())
+}
+
+// Fetches the quotes of a single exchange and writes them to the DB
+func runTicker(exchange Exchange) {
+	// Leave the exchange alone while its breaker is open
+	breaker := breakerFor(exchange)
+	if !breaker.allow() {
+		log.Infof("Skipping %s, circuit breaker is open", exchange.Name())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.FetchTimeout)
+	defer cancel()
+
+	start := time.Now()
+	quotes, err := fetchQuotes(ctx, exchange)
+	recordFetch(exchange.Name(), time.Since(start), err)
+	recordFetchStatus(exchange.Name(), err)
+	if err != nil {
+		log.Errorf("%s: %s", exchange.Name(), err.Error())
+		if breaker.failure(err) {
+			log.Warningf("Circuit breaker opened for %s", exchange.Name())
+		}
+		return
+	}
+	breaker.success()
+
+	// Write to DB
+	rows, err := store.Insert(quotes)
+	if err != nil {
+		log.Warning("%q\n", err)
+		return
+	}
+
+	recordRows(exchange.Name(), rows)
+	recordNewestQuotes(rows)
+
+	// Push the new rows to streaming clients
+	quoteFeed.publish(rows)
+
+	log.Noticef("Ran %s Ticker", exchange.Name())
+
+	// Check the alert rules against the new quotes
+	evaluateAlerts(exchange.Name())
+}
+
+// Runs Fetch in its own goroutine so that exchanges whose API clients
+// ignore the context still can't block the worker past the deadline
+func fetchQuotes(ctx context.Context, exchange Exchange) ([]Quote, error) {
+	type result struct {
+		quotes []Quote
+		err    error
+	}
+
+	// Buffered so the goroutine can finish even if nobody is listening
+	done := make(chan result, 1)
+	go func() {
+		quotes, err := exchange.Fetch(ctx)
+		done <- result{quotes, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.quotes, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
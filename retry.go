@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Returns how long to wait before the next attempt, doubling the base
+// delay on every attempt up to the maximum. Half of the delay is random
+// jitter so that retries against the same exchange don't line up.
+func retryBackoff(attempt int) time.Duration {
+	delay := config.RetryBaseDelay
+	for i := 0; i < attempt && delay < config.RetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > config.RetryMaxDelay {
+		delay = config.RetryMaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// Returns how long to wait before retrying a response, honouring the
+// Retry-After header when the exchange sends one
+func retryAfter(resp *http.Response, attempt int) time.Duration {
+	header := resp.Header.Get("Retry-After")
+	if len(header) == 0 {
+		return retryBackoff(attempt)
+	}
+
+	// Either a number of seconds
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	// Or an HTTP date
+	if date, err := http.ParseTime(header); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+		return 0
+	}
+
+	return retryBackoff(attempt)
+}
+
+// Runs a call to an exchange's API client, which can't be given a context,
+// retrying failures with the same backoff as apiCall. Gives up once ctx is
+// done, leaving the abandoned call to finish on its own.
+func withRetry(ctx context.Context, name string, call func() error) error {
+	for attempt := 0; ; attempt++ {
+		done := make(chan error, 1)
+		go func() { done <- call() }()
+
+		var err error
+		select {
+		case err = <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err == nil {
+			return nil
+		}
+		log.Warningf("%s: %s", name, err.Error())
+
+		// Give up once the retries are used up
+		if attempt >= config.Retries {
+			log.Errorf("Giving up on %s after %d attempts", name, attempt+1)
+			return err
+		}
+
+		// Wait before trying again, unless the deadline passes first
+		select {
+		case <-time.After(retryBackoff(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
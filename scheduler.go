@@ -0,0 +1,167 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// The scheduler that runs the exchange tickers, set up by bitcoinPrices
+var tickerScheduler *scheduler
+
+// A single run of an exchange handed to the worker pool
+type tickerJob struct {
+	exchange Exchange
+	done     chan struct{}
+}
+
+// A running schedule for a single exchange
+type scheduledExchange struct {
+	interval time.Duration
+	stop     chan struct{}
+	// Closed once the schedule has stopped and its last run has finished
+	stopped chan struct{}
+}
+
+// Runs every exchange on its own interval, using a bounded pool of
+// workers to do the actual fetching
+type scheduler struct {
+	mu        sync.Mutex
+	jobs      chan tickerJob
+	quit      chan struct{}
+	workers   int
+	scheduled map[string]*scheduledExchange
+}
+
+// Creates a scheduler and starts its workers
+func newScheduler(workers int) *scheduler {
+	s := &scheduler{
+		jobs:      make(chan tickerJob),
+		quit:      make(chan struct{}),
+		scheduled: make(map[string]*scheduledExchange),
+	}
+	s.resize(workers)
+
+	return s
+}
+
+// Starts or stops workers until there are the given number. Workers that
+// are busy stop once their run has finished.
+func (s *scheduler) resize(workers int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.workers > 0 && s.workers != workers {
+		log.Infof("Resizing the worker pool from %d to %d", s.workers, workers)
+	}
+	for ; s.workers < workers; s.workers++ {
+		go s.worker()
+	}
+	for ; s.workers > workers; s.workers-- {
+		go func() { s.quit <- struct{}{} }()
+	}
+}
+
+// Runs jobs until told to quit
+func (s *scheduler) worker() {
+	for {
+		select {
+		case job := <-s.jobs:
+			runTicker(job.exchange)
+			close(job.done)
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// Starts, restarts, stops or leaves alone the schedule of each exchange
+// so that it matches the current config. Exchanges whose interval did not
+// change keep their current timing.
+func (s *scheduler) reschedule(exchanges []Exchange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, exchange := range exchanges {
+		interval := exchangeInterval(exchange)
+
+		current, ok := s.scheduled[exchange.Key()]
+		if !exchangeConfig(exchange).Enabled {
+			if ok {
+				close(current.stop)
+				delete(s.scheduled, exchange.Key())
+				log.Infof("Stopped polling %s", exchange.Name())
+			}
+			continue
+		}
+
+		if ok && current.interval == interval {
+			continue
+		}
+
+		// Stop the old schedule before starting the new one
+		if ok {
+			close(current.stop)
+			log.Infof("Rescheduling %s every %s", exchange.Name(), interval)
+		} else {
+			log.Infof("Scheduling %s every %s", exchange.Name(), interval)
+		}
+
+		next := &scheduledExchange{
+			interval: interval,
+			stop:     make(chan struct{}),
+			stopped:  make(chan struct{}),
+		}
+		s.scheduled[exchange.Key()] = next
+		go s.run(exchange, next, current)
+	}
+}
+
+// Runs an exchange straight away and then once every interval until
+// stopped. A run that is still in progress is never started twice, not
+// even by the schedule replacing this one, which waits for the previous
+// schedule to have stopped first.
+func (s *scheduler) run(exchange Exchange, sched *scheduledExchange, previous *scheduledExchange) {
+	defer close(sched.stopped)
+
+	if previous != nil {
+		<-previous.stopped
+		select {
+		case <-sched.stop:
+			return
+		default:
+		}
+	}
+
+	ticker := time.NewTicker(sched.interval)
+	defer ticker.Stop()
+
+	for {
+		job := tickerJob{exchange: exchange, done: make(chan struct{})}
+
+		// Wait for a free worker
+		select {
+		case s.jobs <- job:
+		case <-sched.stop:
+			return
+		}
+
+		// Wait for the run to finish, even once stopped
+		<-job.done
+
+		// Wait for the next tick
+		select {
+		case <-ticker.C:
+		case <-sched.stop:
+			return
+		}
+	}
+}
+
+// Returns the polling interval of an exchange, falling back to the
+// global default when the exchange doesn't set one
+func exchangeInterval(exchange Exchange) time.Duration {
+	if interval := exchangeConfig(exchange).Interval; interval > 0 {
+		return interval
+	}
+	return config.Interval
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// Rows held for an SSE client before it is dropped as too slow
+	sseSendBuffer = 256
+	// How often a comment is sent to keep idle connections open
+	sseKeepAlive = 30 * time.Second
+	// How long EventSource clients wait before reconnecting, in ms
+	sseRetry = 3000
+)
+
+// Streams new quotes as Server-Sent Events, optionally only those of an
+// exchange and of a pair. Each event's id is the row id, so clients that
+// reconnect with Last-Event-ID get the rows they missed first.
+func streamEvents(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	query := req.URL.Query()
+
+	exchange, pair, err := parseQuoteFilter(query.Get("exchange"), query.Get("pair"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var lastID int64
+	if header := req.Header.Get("Last-Event-ID"); len(header) > 0 {
+		if lastID, err = strconv.ParseInt(header, 10, 64); err != nil || lastID < 0 {
+			http.Error(w, "Last-Event-ID must be a row id", http.StatusBadRequest)
+			return
+		}
+	}
+
+	match := func(row *APIStruct) bool {
+		if len(exchange) > 0 && row.Exchange != exchange {
+			return false
+		}
+		return !pair.Valid() || (row.Base == pair.Base && row.Quote == pair.Quote)
+	}
+
+	// Subscribe before catching up, so that nothing written in between
+	// is missed. Rows seen in both are skipped by their id.
+	sub := quoteFeed.subscribe(sseSendBuffer, match)
+	defer quoteFeed.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetry)
+	flusher.Flush()
+
+	log.Infof("Called: stream %s %s after %d\n", exchange, pair, lastID)
+
+	// Catch up on the rows written since Last-Event-ID
+	if lastID > 0 {
+		for {
+			rows, err := store.QuotesAfter(exchange, pair, lastID, maxHistoryLimit)
+			if err != nil {
+				return
+			}
+			for _, row := range rows {
+				if err := writeEvent(w, row); err != nil {
+					return
+				}
+				lastID = row.ID
+			}
+			flusher.Flush()
+
+			if len(rows) < maxHistoryLimit {
+				break
+			}
+		}
+	}
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case row, ok := <-sub.C:
+			if !ok {
+				// Dropped for being too slow, the client reconnects with
+				// Last-Event-ID and catches up from the DB
+				return
+			}
+			if row.ID <= lastID {
+				continue
+			}
+			if err := writeEvent(w, row); err != nil {
+				return
+			}
+			lastID = row.ID
+			flusher.Flush()
+
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+// Writes a row as a quote event
+func writeEvent(w http.ResponseWriter, row *APIStruct) error {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: quote\ndata: %s\n\n", row.ID, data)
+	return err
+}
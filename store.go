@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Store is implemented by every storage backend
+type Store interface {
+	// Setup brings the schema up to date and gets the store ready for use
+	Setup() error
+	// Migrate runs any pending schema migrations, returning how many ran
+	Migrate() (int, error)
+	// SchemaVersion returns the version of the newest applied migration
+	SchemaVersion() (int, error)
+	// Insert writes the quotes of a single poll in one transaction and
+	// returns the rows that were written
+	Insert(quotes []Quote) ([]*APIStruct, error)
+	// Latest returns the newest quote of a market
+	Latest(m Market) (*APIStruct, error)
+	// QuoteAt returns the last quote of a market at or before timestamp
+	QuoteAt(m Market, timestamp int64) (*APIStruct, error)
+	// LatestQuotes returns the newest quote of every exchange listing the
+	// pair, or of every exchange and pair if the pair is empty
+	LatestQuotes(pair Pair) ([]*APIStruct, error)
+	// CurrencyCodes returns the currency codes stored for an exchange
+	CurrencyCodes(exchange string) ([]string, error)
+	// Exchanges returns the names of every stored exchange
+	Exchanges() ([]string, error)
+	// History returns up to limit quotes between from and to, starting
+	// after the cursor row id, oldest first
+	History(m Market, from int64, to int64, cursor int64, limit int64) ([]*APIStruct, error)
+	// Export calls fn with every quote between from and to, oldest first,
+	// without loading them all into memory. An empty exchange or pair
+	// matches any.
+	Export(exchange string, pair Pair, from int64, to int64, fn func(*APIStruct) error) error
+	// QuotesAfter returns up to limit quotes written after the row id,
+	// oldest first. An empty exchange or pair matches any.
+	QuotesAfter(exchange string, pair Pair, after int64, limit int64) ([]*APIStruct, error)
+	// Ticks calls fn for every mid price between from and to, oldest
+	// first, without loading them all into memory
+	Ticks(m Market, from int64, to int64, fn func(Tick) error) error
+	// InsertArbitrage writes the spreads computed at timestamp
+	InsertArbitrage(timestamp int64, spreads []*ArbitrageSpread) error
+	// InsertPremiums writes the premiums computed at timestamp
+	InsertPremiums(timestamp int64, premiums []*Premium) error
+	// ArbitrageHistory returns up to limit stored spreads of a pair
+	// between from and to, oldest first
+	ArbitrageHistory(pair Pair, from int64, to int64, limit int64) ([]*ArbitrageSpread, error)
+	// PremiumHistory returns up to limit stored premiums of an exchange
+	// and pair between from and to, oldest first
+	PremiumHistory(exchange string, pair Pair, from int64, to int64, limit int64) ([]*Premium, error)
+	// AlertRules returns every alert rule
+	AlertRules() ([]*AlertRule, error)
+	// AlertRule returns a single alert rule
+	AlertRule(id int64) (*AlertRule, error)
+	// SaveAlertRule inserts a new rule or updates an existing one
+	SaveAlertRule(r *AlertRule) error
+	// DeleteAlertRule removes an alert rule
+	DeleteAlertRule(id int64) error
+	// LastID returns the id of the newest quote, or 0 if there are none
+	LastID() (int64, error)
+	// Ping checks that the database can still be reached
+	Ping(ctx context.Context) error
+	// Size returns the size of the database in bytes
+	Size() (int64, error)
+	// Close closes the connection to the database
+	Close() error
+}
+
+// A single mid price used for aggregation
+type Tick struct {
+	Timestamp int64
+	Price     float64
+	Volume    float64
+}
+
+// The store used by the tickers and the API, set up by main
+var store Store
+
+// Opens the store selected by the [database] section of the config file
+func openStore(c Config) (Store, error) {
+	switch strings.ToLower(c.Database.Driver) {
+	case "", "sqlite", "sqlite3":
+		return openSQLiteStore(c.SqliteLocation)
+	case "postgres", "postgresql":
+		return openPostgresStore(c.Database.DSN)
+	case "mysql", "mariadb":
+		return openMySQLStore(c.Database.DSN)
+	}
+	return nil, errors.New("Unknown database driver " + c.Database.Driver)
+}
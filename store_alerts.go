@@ -0,0 +1,110 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Columns scanned into an AlertRule
+const alertRuleColumns = `id, name, source, kind, exchange, base, quote, operator, threshold,
+			windowSeconds, indexPair, webhook, secret, enabled`
+
+// Scans a row selected with alertRuleColumns
+func scanAlertRule(row scanner) (*AlertRule, error) {
+	var (
+		r             = &AlertRule{}
+		base, quote   string
+		windowSeconds int64
+		enabled       int
+	)
+	err := row.Scan(&r.ID, &r.Name, &r.Source, &r.Kind, &r.Exchange, &base, &quote, &r.Operator, &r.Threshold,
+		&windowSeconds, &r.IndexPair, &r.Webhook, &r.Secret, &enabled)
+	if err != nil {
+		return nil, err
+	}
+	r.Pair = base + "-" + quote
+	r.Window = jsonDuration(time.Duration(windowSeconds) * time.Second)
+	r.Enabled = enabled != 0
+	return r, nil
+}
+
+// SELECT every alert rule
+func (s *sqlStore) AlertRules() ([]*AlertRule, error) {
+	rows, err := s.db.Query(`select ` + alertRuleColumns + ` from alert_rules order by id asc;`)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	resp := []*AlertRule{}
+	for rows.Next() {
+		r, err := scanAlertRule(rows)
+		if err != nil {
+			log.Warning("%q\n", err)
+			return nil, err
+		}
+		resp = append(resp, r)
+	}
+	return resp, rows.Err()
+}
+
+// SELECT a single alert rule
+func (s *sqlStore) AlertRule(id int64) (*AlertRule, error) {
+	r, err := scanAlertRule(s.db.QueryRow(s.dialect.rebind(`select `+alertRuleColumns+` from alert_rules where id = ?;`), id))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("Alert rule doesn't exist")
+	}
+	return r, err
+}
+
+// Inserts the rule if it has no id yet, otherwise updates it. The id of
+// new rules is filled in.
+func (s *sqlStore) SaveAlertRule(r *AlertRule) error {
+	pair, err := parsePair(r.Pair)
+	if err != nil {
+		return err
+	}
+
+	enabled := 0
+	if r.Enabled {
+		enabled = 1
+	}
+	args := []interface{}{r.Name, r.Source, r.Kind, r.Exchange, pair.Base, pair.Quote, r.Operator, r.Threshold,
+		int64(time.Duration(r.Window) / time.Second), r.IndexPair, r.Webhook, r.Secret, enabled}
+
+	if r.ID > 0 {
+		_, err := s.db.Exec(s.dialect.rebind(`update alert_rules set name = ?, source = ?, kind = ?, exchange = ?, base = ?, quote = ?,
+				operator = ?, threshold = ?, windowSeconds = ?, indexPair = ?, webhook = ?, secret = ?, enabled = ?
+				where id = ?;`), append(args, r.ID)...)
+		return err
+	}
+
+	query := `insert into alert_rules (name, source, kind, exchange, base, quote, operator, threshold,
+			windowSeconds, indexPair, webhook, secret, enabled) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	// PostgreSQL doesn't report the last insert id
+	if s.dialect.name == "postgres" {
+		return s.db.QueryRow(s.dialect.rebind(query+` returning id;`), args...).Scan(&r.ID)
+	}
+
+	res, err := s.db.Exec(query+`;`, args...)
+	if err != nil {
+		return err
+	}
+	r.ID, err = res.LastInsertId()
+	return err
+}
+
+// DELETE an alert rule
+func (s *sqlStore) DeleteAlertRule(id int64) error {
+	res, err := s.db.Exec(s.dialect.rebind(`delete from alert_rules where id = ?;`), id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errors.New("Alert rule doesn't exist")
+	}
+	return nil
+}
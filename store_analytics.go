@@ -0,0 +1,106 @@
+package main
+
+// Insert the spreads computed at timestamp in a single transaction
+func (s *sqlStore) InsertArbitrage(timestamp int64, spreads []*ArbitrageSpread) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(s.dialect.rebind(`insert into arbitrage_history (timestamp, base, quote, buyExchange, buyPrice, sellExchange, sellPrice, spread, exchangeCount) values (?, ?, ?, ?, ?, ?, ?, ?, ?);`))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, a := range spreads {
+		if _, err := stmt.Exec(timestamp, a.Base, a.Quote, a.BuyExchange, a.BuyPrice, a.SellExchange, a.SellPrice, a.Spread, a.Exchanges); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Insert the premiums computed at timestamp in a single transaction
+func (s *sqlStore) InsertPremiums(timestamp int64, premiums []*Premium) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(s.dialect.rebind(`insert into premium_history (timestamp, exchange, base, quote, price, referenceExchange, referenceBase, referenceQuote, referencePrice, fiatRate, premium) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, p := range premiums {
+		reference, _ := parsePair(p.ReferencePair)
+		if _, err := stmt.Exec(timestamp, p.Exchange, p.Base, p.Quote, p.Price, p.ReferenceExchange, reference.Base, reference.Quote, p.ReferencePrice, p.FiatRate, p.Premium); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SELECT the stored spreads of a pair between from and to
+func (s *sqlStore) ArbitrageHistory(pair Pair, from int64, to int64, limit int64) ([]*ArbitrageSpread, error) {
+	rows, err := s.db.Query(s.dialect.rebind(`select base, quote, buyExchange, buyPrice, sellExchange, sellPrice, spread, exchangeCount,
+			`+s.dialect.dateTime+` as timestamp
+			from arbitrage_history
+			where base = ? and quote = ? and timestamp >= ? and timestamp <= ?
+			order by timestamp asc LIMIT ?;`), pair.Base, pair.Quote, from, to, limit)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	resp := []*ArbitrageSpread{}
+	for rows.Next() {
+		a := &ArbitrageSpread{}
+		if err := rows.Scan(&a.Base, &a.Quote, &a.BuyExchange, &a.BuyPrice, &a.SellExchange, &a.SellPrice, &a.Spread, &a.Exchanges, &a.DateUpdated); err != nil {
+			log.Warning("%q\n", err)
+			return nil, err
+		}
+		a.Pair = a.Base + "-" + a.Quote
+		resp = append(resp, a)
+	}
+	return resp, rows.Err()
+}
+
+// SELECT the stored premiums of an exchange and pair between from and to
+func (s *sqlStore) PremiumHistory(exchange string, pair Pair, from int64, to int64, limit int64) ([]*Premium, error) {
+	rows, err := s.db.Query(s.dialect.rebind(`select exchange, base, quote, price, referenceExchange, referenceBase, referenceQuote,
+			referencePrice, fiatRate, premium, `+s.dialect.dateTime+` as timestamp
+			from premium_history
+			where exchange = ? and base = ? and quote = ? and timestamp >= ? and timestamp <= ?
+			order by timestamp asc LIMIT ?;`), exchange, pair.Base, pair.Quote, from, to, limit)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	resp := []*Premium{}
+	for rows.Next() {
+		var (
+			p                             = &Premium{}
+			referenceBase, referenceQuote string
+		)
+		if err := rows.Scan(&p.Exchange, &p.Base, &p.Quote, &p.Price, &p.ReferenceExchange, &referenceBase, &referenceQuote,
+			&p.ReferencePrice, &p.FiatRate, &p.Premium, &p.DateUpdated); err != nil {
+			log.Warning("%q\n", err)
+			return nil, err
+		}
+		p.Pair = p.Base + "-" + p.Quote
+		p.ReferencePair = referenceBase + "-" + referenceQuote
+		p.ConvertedReferencePrice = p.ReferencePrice * p.FiatRate
+		resp = append(resp, p)
+	}
+	return resp, rows.Err()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL and MariaDB flavour of SQL
+var mysqlDialect = sqlDialect{
+	name:        "mysql",
+	dateTime:    `DATE_FORMAT(FROM_UNIXTIME(timestamp), '%Y-%m-%d %H:%i:%s')`,
+	midPrice:    `ROUND((ask + bid) / 2, 8)`,
+	size:        `select COALESCE(SUM(data_length + index_length), 0) from information_schema.tables where table_schema = DATABASE();`,
+	columnCount: `select count(*) from information_schema.columns where table_schema = DATABASE() and table_name = ? and column_name = ?;`,
+}
+
+// Open MySQL Connection
+func openMySQLStore(dsn string) (Store, error) {
+	if len(dsn) == 0 {
+		return nil, errors.New("database.dsn is required for mysql")
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// FROM_UNIXTIME uses the session time zone, dates are returned in UTC
+	if cfg.Params == nil {
+		cfg.Params = make(map[string]string)
+	}
+	cfg.Params["time_zone"] = "'+00:00'"
+
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+
+	// Fail at startup rather than on the first insert
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &sqlStore{db: db, dialect: mysqlDialect}, nil
+}
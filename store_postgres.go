@@ -0,0 +1,38 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+
+	_ "github.com/lib/pq"
+)
+
+// PostgreSQL flavour of SQL
+var postgresDialect = sqlDialect{
+	name:                 "postgres",
+	dateTime:             `to_char(to_timestamp(timestamp) at time zone 'UTC', 'YYYY-MM-DD HH24:MI:SS')`,
+	midPrice:             `ROUND(CAST((ask + bid) / 2 AS NUMERIC), 8)`,
+	numberedPlaceholders: true,
+	size:                 `select pg_database_size(current_database());`,
+	columnCount:          `select count(*) from information_schema.columns where table_schema = current_schema() and table_name = ? and column_name = ?;`,
+}
+
+// Open PostgreSQL Connection
+func openPostgresStore(dsn string) (Store, error) {
+	if len(dsn) == 0 {
+		return nil, errors.New("database.dsn is required for postgres")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fail at startup rather than on the first insert
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &sqlStore{db: db, dialect: postgresDialect}, nil
+}
@@ -0,0 +1,494 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The bits of SQL that differ between databases
+type sqlDialect struct {
+	// Name used to pick the statements of a migration
+	name string
+	// Expression formatting the timestamp column as a date time
+	dateTime string
+	// Expression computing the mid price rounded to 8 decimals
+	midPrice string
+	// Whether placeholders are numbered ($1) rather than ?
+	numberedPlaceholders bool
+	// Query returning the size of the database in bytes
+	size string
+	// Query counting the columns of a table (first argument) with a name
+	// (second argument)
+	columnCount string
+}
+
+// Rewrites ? placeholders into the form used by the database
+func (d sqlDialect) rebind(query string) string {
+	if !d.numberedPlaceholders {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Store backed by a database/sql database, shared by the SQL backends.
+// The database handle is opened once and kept for the life of the store.
+type sqlStore struct {
+	db      *sql.DB
+	dialect sqlDialect
+	insert  *sql.Stmt
+}
+
+// Brings the schema up to date and prepares the statements
+func (s *sqlStore) Setup() error {
+	if _, err := s.Migrate(); err != nil {
+		return err
+	}
+	return s.prepare()
+}
+
+// Prepares the statements used on every poll. Needs the table to exist.
+func (s *sqlStore) prepare() (err error) {
+	query := `insert into exchanges (exchange, timestamp, ask, bid, volume, currencyCode, base, quote) values (?, ?, ?, ?, ?, ?, ?, ?)`
+	if s.dialect.name == "postgres" {
+		query += ` returning id`
+	}
+	s.insert, err = s.db.Prepare(s.dialect.rebind(query + `;`))
+	return err
+}
+
+// Insert the quotes of a poll into the exchanges table in a single
+// transaction. Quotes that don't hold numbers are skipped.
+func (s *sqlStore) Insert(quotes []Quote) ([]*APIStruct, error) {
+	if s.insert == nil {
+		return nil, errors.New("Store has not been set up")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	// Use the prepared statement within the transaction
+	stmt := tx.Stmt(s.insert)
+	defer stmt.Close()
+
+	var rows []*APIStruct
+
+	for _, q := range quotes {
+
+		// If the exchange name is not there, ignore, otherwise run
+		if len(q.Exchange) == 0 || len(q.CurrencyCode) == 0 {
+			continue
+		}
+
+		// Clean strings, if the string doesn't contain anything, default
+		cleanStrings(&q.Timestamp, &q.Ask, &q.Bid, &q.Volume)
+
+		// Don't let a malformed response abort the whole transaction
+		if err := validateQuote(q); err != nil {
+			log.Warningf("Skipping %s %s: %s", q.Exchange, q.CurrencyCode, err.Error())
+			continue
+		}
+
+		id, err := s.insertQuote(stmt, q)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		rows = append(rows, storedQuote(id, q))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Runs the insert statement for a quote and returns the id of the new row
+func (s *sqlStore) insertQuote(stmt *sql.Stmt, q Quote) (int64, error) {
+	args := []interface{}{q.Exchange, q.Timestamp, q.Ask, q.Bid, q.Volume, q.CurrencyCode, q.Pair.Base, q.Pair.Quote}
+
+	var id int64
+	if s.dialect.name == "postgres" {
+		err := stmt.QueryRow(args...).Scan(&id)
+		return id, err
+	}
+
+	res, err := stmt.Exec(args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// Builds the row written for a quote, as it would be selected with
+// quoteColumns. The quote must have passed validateQuote.
+func storedQuote(id int64, q Quote) *APIStruct {
+	timestamp, _ := strconv.ParseFloat(q.Timestamp, 64)
+	ask, _ := strconv.ParseFloat(q.Ask, 64)
+	bid, _ := strconv.ParseFloat(q.Bid, 64)
+	volume, _ := strconv.ParseFloat(q.Volume, 64)
+
+	return &APIStruct{
+		ID:           id,
+		Exchange:     q.Exchange,
+		CurrencyCode: q.CurrencyCode,
+		Base:         q.Pair.Base,
+		Quote:        q.Pair.Quote,
+		Bid:          bid,
+		Ask:          ask,
+		Average:      math.Round((ask+bid)/2*1e8) / 1e8,
+		DateUpdated:  time.Unix(int64(timestamp), 0).UTC().Format("2006-01-02 15:04:05"),
+		Volume:       volume,
+		Timestamp:    timestamp,
+	}
+}
+
+// SELECT the latest quote of a market
+func (s *sqlStore) Latest(m Market) (*APIStruct, error) {
+
+	where, args := m.where()
+
+	// Query for data
+	response := s.db.QueryRow(s.dialect.rebind(`select `+quoteColumns(s.dialect)+`
+			from exchanges
+			where `+where+` order by ID desc LIMIT 1;`), args...)
+
+	// Scan data into response
+	tmp, err := scanQuote(response)
+	if err != nil {
+		log.Warning("%q\n", err)
+		return nil, errors.New("No values found")
+	}
+
+	// return response
+	return tmp, nil
+}
+
+// SELECT the last quote of a market at or before timestamp
+func (s *sqlStore) QuoteAt(m Market, timestamp int64) (*APIStruct, error) {
+
+	where, args := m.where()
+	args = append(args, timestamp)
+
+	response := s.db.QueryRow(s.dialect.rebind(`select `+quoteColumns(s.dialect)+`
+			from exchanges
+			where `+where+` and timestamp <= ? order by timestamp desc, id desc LIMIT 1;`), args...)
+
+	tmp, err := scanQuote(response)
+	if err != nil {
+		return nil, errors.New("No values found")
+	}
+	return tmp, nil
+}
+
+// SELECT the currency codes of an exchange
+func (s *sqlStore) CurrencyCodes(exchange string) ([]string, error) {
+
+	// If the exchange name is not there, ignore, otherwise run
+	if len(exchange) == 0 {
+		log.Warning("Nothing was queried!")
+		return nil, errors.New("Exchange doesn't exists")
+	}
+
+	resp, err := s.queryStrings(s.dialect.rebind(`select DISTINCT currencyCode from exchanges where exchange = ?;`), exchange)
+	if err != nil {
+		return nil, err
+	}
+
+	// If anything was returned
+	if len(resp) == 0 {
+		return nil, errors.New("Exchange doesn't exist")
+	}
+	return resp, nil
+}
+
+// SELECT the list of exchanges
+func (s *sqlStore) Exchanges() ([]string, error) {
+	resp, err := s.queryStrings(`select DISTINCT exchange from exchanges;`)
+	if err != nil {
+		return nil, err
+	}
+
+	// If anything was returned
+	if len(resp) == 0 {
+		return nil, errors.New("No exchanges exist")
+	}
+	return resp, nil
+}
+
+// Runs a query returning a single string column
+func (s *sqlStore) queryStrings(query string, args ...interface{}) ([]string, error) {
+	rows, err := s.db.Query(query, args...)
+	// Check if there are errors
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Scan the values into a string slice
+	var resp []string
+	for rows.Next() {
+		var tmp string
+		if err := rows.Scan(&tmp); err != nil {
+			return nil, err
+		}
+		resp = append(resp, tmp)
+	}
+	return resp, rows.Err()
+}
+
+// SELECT a page of quotes between from and to
+func (s *sqlStore) History(m Market, from int64, to int64, cursor int64, limit int64) ([]*APIStruct, error) {
+
+	where, args := m.where()
+	args = append(args, from, to, cursor, limit)
+
+	rows, err := s.db.Query(s.dialect.rebind(`select `+quoteColumns(s.dialect)+`
+			from exchanges
+			where `+where+` and timestamp >= ? and timestamp <= ? and id > ?
+			order by id asc LIMIT ?;`), args...)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Scan the rows into the response
+	return scanQuotes(rows)
+}
+
+// SELECT up to limit quotes written after the row id, optionally only
+// those of an exchange and of a pair
+func (s *sqlStore) QuotesAfter(exchange string, pair Pair, after int64, limit int64) ([]*APIStruct, error) {
+
+	where := `id > ?`
+	args := []interface{}{after}
+	if len(exchange) > 0 {
+		where += ` and exchange = ?`
+		args = append(args, exchange)
+	}
+	if pair.Valid() {
+		where += ` and base = ? and quote = ?`
+		args = append(args, pair.Base, pair.Quote)
+	}
+	args = append(args, limit)
+
+	rows, err := s.db.Query(s.dialect.rebind(`select `+quoteColumns(s.dialect)+`
+			from exchanges
+			where `+where+`
+			order by id asc LIMIT ?;`), args...)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanQuotes(rows)
+}
+
+// SELECT the quotes between from and to, optionally only those of an
+// exchange and of a pair, oldest first. Rows are read in batches so that
+// a slow reader never holds the database for long.
+func (s *sqlStore) Export(exchange string, pair Pair, from int64, to int64, fn func(*APIStruct) error) error {
+
+	where := `id > ? and timestamp >= ? and timestamp <= ?`
+	if len(exchange) > 0 {
+		where += ` and exchange = ?`
+	}
+	if pair.Valid() {
+		where += ` and base = ? and quote = ?`
+	}
+	query := s.dialect.rebind(`select ` + quoteColumns(s.dialect) + `
+			from exchanges
+			where ` + where + `
+			order by id asc LIMIT ?;`)
+
+	var after int64
+	for {
+		args := []interface{}{after, from, to}
+		if len(exchange) > 0 {
+			args = append(args, exchange)
+		}
+		if pair.Valid() {
+			args = append(args, pair.Base, pair.Quote)
+		}
+		args = append(args, exportBatchSize)
+
+		rows, err := s.db.Query(query, args...)
+		if err != nil {
+			log.Error(err.Error())
+			return err
+		}
+		batch, err := scanQuotes(rows)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, row := range batch {
+			if err := fn(row); err != nil {
+				return err
+			}
+			after = row.ID
+		}
+
+		if len(batch) < exportBatchSize {
+			return nil
+		}
+	}
+}
+
+// SELECT the latest quote of every exchange listing the pair, or of
+// every exchange and pair if the pair is empty
+func (s *sqlStore) LatestQuotes(pair Pair) ([]*APIStruct, error) {
+	var (
+		where string
+		args  []interface{}
+	)
+	if pair.Valid() {
+		where = `where base = ? and quote = ?`
+		args = []interface{}{pair.Base, pair.Quote}
+	}
+
+	rows, err := s.db.Query(s.dialect.rebind(`select `+quoteColumns(s.dialect)+`
+			from exchanges
+			where id in (select MAX(id) from exchanges `+where+` group by exchange, base, quote)
+			order by exchange asc;`), args...)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanQuotes(rows)
+}
+
+// Anything a quote can be scanned from, a row or rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Scans a row selected with quoteColumns
+func scanQuote(row scanner) (*APIStruct, error) {
+	tmp := &APIStruct{}
+	err := row.Scan(&tmp.ID, &tmp.Exchange, &tmp.Ask, &tmp.Bid, &tmp.Average, &tmp.Volume, &tmp.DateUpdated, &tmp.CurrencyCode, &tmp.Base, &tmp.Quote, &tmp.Timestamp)
+	return tmp, err
+}
+
+// Scans every row selected with quoteColumns
+func scanQuotes(rows *sql.Rows) ([]*APIStruct, error) {
+	resp := []*APIStruct{}
+	for rows.Next() {
+		tmp, err := scanQuote(rows)
+		if err != nil {
+			log.Warning("%q\n", err)
+			return nil, err
+		}
+		resp = append(resp, tmp)
+	}
+	return resp, rows.Err()
+}
+
+// SELECT the mid prices between from and to, one row at a time
+func (s *sqlStore) Ticks(m Market, from int64, to int64, fn func(Tick) error) error {
+
+	where, args := m.where()
+	args = append(args, from, to)
+
+	rows, err := s.db.Query(s.dialect.rebind(`select timestamp, `+s.dialect.midPrice+` as price, volume
+			from exchanges
+			where `+where+` and timestamp >= ? and timestamp < ?
+			order by timestamp asc, id asc;`), args...)
+	if err != nil {
+		log.Error(err.Error())
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			timestamp float64
+			tick      Tick
+		)
+		if err := rows.Scan(&timestamp, &tick.Price, &tick.Volume); err != nil {
+			log.Warning("%q\n", err)
+			return err
+		}
+		tick.Timestamp = int64(timestamp)
+		if err := fn(tick); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Columns scanned into an APIStruct
+func quoteColumns(d sqlDialect) string {
+	return `id, exchange, ask, bid, ` + d.midPrice + ` as price,
+			volume as volume, ` + d.dateTime + ` as timestamp, currencyCode,
+			COALESCE(base, ''), COALESCE(quote, ''), timestamp as unixTimestamp`
+}
+
+// SELECT the id of the newest quote
+func (s *sqlStore) LastID() (int64, error) {
+	var id int64
+	err := s.db.QueryRow(`select COALESCE(MAX(id), 0) from exchanges;`).Scan(&id)
+	return id, err
+}
+
+// Checks that the database can still be reached
+func (s *sqlStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Returns the size of the database in bytes
+func (s *sqlStore) Size() (int64, error) {
+	var size int64
+	err := s.db.QueryRow(s.dialect.size).Scan(&size)
+	return size, err
+}
+
+// Closes the database
+func (s *sqlStore) Close() error {
+	if s.insert != nil {
+		s.insert.Close()
+	}
+	return s.db.Close()
+}
+
+// Checks that the numeric fields of a quote hold numbers
+func validateQuote(q Quote) error {
+	fields := []struct {
+		name  string
+		value string
+	}{
+		{"timestamp", q.Timestamp},
+		{"ask", q.Ask},
+		{"bid", q.Bid},
+		{"volume", q.Volume},
+	}
+	for _, field := range fields {
+		if _, err := strconv.ParseFloat(field.value, 64); err != nil {
+			return errors.New(field.name + " is not a number: " + strconv.Quote(field.value))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/op/go-logging"
+)
+
+// Number of quotes in a benchmarked poll, about the size of a Poloniex poll
+const benchPollSize = 100
+
+// Builds a poll worth of quotes
+func benchQuotes(n int) []Quote {
+	quotes := make([]Quote, n)
+	for i := range quotes {
+		quotes[i] = Quote{
+			Exchange:     "Poloniex",
+			Timestamp:    "1500000000",
+			Ask:          "2501.12345678",
+			Bid:          "2499.87654321",
+			Volume:       "1234.5678",
+			CurrencyCode: "BTC_" + strconv.Itoa(i),
+			Pair:         Pair{Base: "BTC", Quote: "USD"},
+		}
+	}
+	return quotes
+}
+
+// Opens a store on a fresh sqlite database with the schema in place
+func benchStore(b *testing.B) (*sqlStore, string) {
+	// Keep the migration log out of the results
+	logging.SetLevel(logging.WARNING, "")
+
+	path := filepath.Join(b.TempDir(), "data.db")
+	s, err := openSQLiteStore(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := s.Setup(); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { s.Close() })
+	return s.(*sqlStore), path
+}
+
+// Writes each poll through the prepared insert in a single transaction
+func BenchmarkInsertPoll(b *testing.B) {
+	s, _ := benchStore(b)
+	quotes := benchQuotes(benchPollSize)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.Insert(quotes); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(b.N*benchPollSize)/b.Elapsed().Seconds(), "rows/s")
+}
+
+// Writes each poll the way insertIntoSQLite used to: one database handle
+// and one concatenated statement per row, without a transaction
+func BenchmarkInsertPollPerRow(b *testing.B) {
+	_, path := benchStore(b)
+	quotes := benchQuotes(benchPollSize)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, q := range quotes {
+			db, err := sql.Open("sqlite3", path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			sqlStmt := `insert into exchanges (exchange, timestamp, ask, bid, volume, currencyCode) values ('` + q.Exchange + `',` + q.Timestamp + `, ` + q.Ask + `,` + q.Bid + `, ` + q.Volume + `, '` + q.CurrencyCode + `');`
+			if _, err := db.Exec(sqlStmt); err != nil {
+				b.Fatal(err)
+			}
+			db.Close()
+		}
+	}
+	b.ReportMetric(float64(b.N*benchPollSize)/b.Elapsed().Seconds(), "rows/s")
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLite flavour of SQL
+var sqliteDialect = sqlDialect{
+	name:        "sqlite",
+	dateTime:    `datetime(timestamp, 'unixepoch')`,
+	midPrice:    `ROUND((ask + bid) / 2, 8)`,
+	size:        `select page_count * page_size from pragma_page_count(), pragma_page_size();`,
+	columnCount: `select count(*) from pragma_table_info(?) where name = ?;`,
+}
+
+// Open SQlite Connection to the database at path, creating its directory
+// if needed
+func openSQLiteStore(path string) (Store, error) {
+	if len(path) == 0 {
+		return nil, errors.New("No location for the sqlite database, set sqliteLocation or --data-dir")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite only allows a single writer
+	db.SetMaxOpenConns(1)
+
+	return &sqlStore{db: db, dialect: sqliteDialect}, nil
+}
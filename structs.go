@@ -1,141 +1,209 @@
 package main
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // Config type
 type Config struct {
-	LogFile        string
-	SqliteLocation string
-	Port           string
-	Kraken         KrakenConfig
-	Luno           LunoConfig
-	Bitstamp       BitstampConfig
-	Bitfinex       BitfinexConfig
-	Bitsquare      BitsquareConfig
-	BTCC           BtccConfig
-	OKCoin         OKCoinConfig
-	Poloniex       PoloniexConfig
-}
-
-type KrakenConfig struct {
+	LogFile          string
+	SqliteLocation   string
+	Port             string
+	Database         DatabaseConfig
+	Workers          int
+	FetchTimeout     time.Duration
+	Interval         time.Duration
+	RequestTimeout   time.Duration
+	Retries          int
+	RetryBaseDelay   time.Duration
+	RetryMaxDelay    time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	StaleAfter       float64
+	AlertSecret      string
+	AlertToken       string
+	Alerts           []*AlertRule
+	Exchanges        map[string]ExchangeConfig
+	Aliases          map[string]string
+	Analytics        AnalyticsConfig
+}
+
+// Settings of the [database] section
+type DatabaseConfig struct {
+	Driver string
+	DSN    string
+}
+
+// Settings of the [analytics] section
+type AnalyticsConfig struct {
+	Interval          time.Duration
+	ReferenceExchange string
+	ReferencePair     string
+	FiatRatesURL      string
+	FiatBase          string
+	FiatRates         map[string]float64
+}
+
+// Settings of a single [exchanges.*] section
+type ExchangeConfig struct {
+	Enabled   bool
 	URL       string
 	APIKey    string
 	APISecret string
-}
-
-type LunoConfig struct {
-	URL string
-}
-
-type BitstampConfig struct {
-	URL string
-}
-
-type BitfinexConfig struct {
-	URL     string
-	Tickers string
-}
-
-type BitsquareConfig struct {
-	URL     string
-	Tickers string
-}
-
-type BtccConfig struct {
-	URL     string
-	Tickers string
-}
-
-type OKCoinConfig struct {
-	URL     string
-	Tickers string
-}
-
-type PoloniexConfig struct {
-	APIKey    string
-	APISecret string
+	Tickers   string
+	Interval  time.Duration
 }
 
 // API Response
 type APIStruct struct {
+	ID           int64   `json:"id,omitempty"`
 	Exchange     string  `json:"exchange"`
 	CurrencyCode string  `json:"currencyCode"`
+	Base         string  `json:"base,omitempty"`
+	Quote        string  `json:"quote,omitempty"`
 	Bid          float64 `json:"bid"`
 	Ask          float64 `json:"ask"`
 	Average      float64 `json:"average"`
 	DateUpdated  string  `json:"dateUpdated"`
 	Volume       float64 `json:"volume"`
+	Timestamp    float64 `json:"-"`
+}
+
+// OHLC candle computed from the mid price
+type Candle struct {
+	start    int64
+	OpenTime string  `json:"openTime"`
+	Open     float64 `json:"open"`
+	High     float64 `json:"high"`
+	Low      float64 `json:"low"`
+	Close    float64 `json:"close"`
+	Volume   float64 `json:"volume"`
+	Ticks    int     `json:"ticks"`
+}
+
+// Reference price combined from several exchanges
+type PriceIndex struct {
+	Pair         string              `json:"pair"`
+	Method       string              `json:"method"`
+	Price        float64             `json:"price"`
+	DateUpdated  string              `json:"dateUpdated"`
+	Contributors []*IndexContributor `json:"contributors"`
+	Excluded     []*IndexContributor `json:"excluded"`
+}
+
+// A quote that went into, or was left out of, an index
+type IndexContributor struct {
+	Exchange    string  `json:"exchange"`
+	Price       float64 `json:"price"`
+	Volume      float64 `json:"volume"`
+	Weight      float64 `json:"weight"`
+	DateUpdated string  `json:"dateUpdated"`
+	Reason      string  `json:"reason,omitempty"`
+}
+
+// Amount converted between two assets
+type Conversion struct {
+	From   string           `json:"from"`
+	To     string           `json:"to"`
+	Amount float64          `json:"amount"`
+	Result float64          `json:"result"`
+	Rate   float64          `json:"rate"`
+	Path   []*ConversionLeg `json:"path"`
+}
+
+// A single step of a conversion. Inverted legs go from the quote
+// currency of the pair to its base.
+type ConversionLeg struct {
+	From        string  `json:"from"`
+	To          string  `json:"to"`
+	Exchange    string  `json:"exchange"`
+	Pair        string  `json:"pair"`
+	Rate        float64 `json:"rate"`
+	Inverted    bool    `json:"inverted"`
+	DateUpdated string  `json:"dateUpdated"`
+	Age         int64   `json:"ageSeconds"`
+}
+
+// Best place to buy and to sell a pair listed on several exchanges
+type ArbitrageSpread struct {
+	Pair         string  `json:"pair"`
+	Base         string  `json:"-"`
+	Quote        string  `json:"-"`
+	BuyExchange  string  `json:"buyExchange"`
+	BuyPrice     float64 `json:"buyPrice"`
+	SellExchange string  `json:"sellExchange"`
+	SellPrice    float64 `json:"sellPrice"`
+	Spread       float64 `json:"spread"`
+	Exchanges    int     `json:"exchanges"`
+	DateUpdated  string  `json:"dateUpdated"`
 }
 
-// Luno Ticker
-type LunoTicker struct {
-	Tickers []struct {
-		Timestamp           int64  `json:"timestamp"`
-		Bid                 string `json:"bid"`
-		Ask                 string `json:"ask"`
-		LastTrade           string `json:"last_trade"`
-		Rolling24HourVolume string `json:"rolling_24_hour_volume"`
-		Pair                string `json:"pair"`
-	} `json:"tickers"`
-}
-
-type Bitstamp struct {
-	High      string `json:"high"`
-	Last      string `json:"last"`
-	Timestamp string `json:"timestamp"`
-	Bid       string `json:"bid"`
-	Vwap      string `json:"vwap"`
-	Volume    string `json:"volume"`
-	Low       string `json:"low"`
-	Ask       string `json:"ask"`
-	Open      string `json:"open"`
-}
-
-type Bitfinex struct {
-	Ask       string `json:"ask"`
-	Bid       string `json:"bid"`
-	High      string `json:"high"`
-	LastPrice string `json:"last_price"`
-	Low       string `json:"low"`
-	Mid       string `json:"mid"`
-	Timestamp string `json:"timestamp"`
-	Volume    string `json:"volume"`
-}
-
-type Bitsquare struct {
-	Buy         string `json:"buy"`
-	High        string `json:"high"`
-	Last        string `json:"last"`
-	Low         string `json:"low"`
-	Sell        string `json:"sell"`
-	VolumeLeft  string `json:"volume_left"`
-	VolumeRight string `json:"volume_right"`
-}
-
-type BTCC struct {
-	Ticker struct {
-		AskPrice           float64 `json:"AskPrice"`
-		BidPrice           float64 `json:"BidPrice"`
-		ExecutionLimitDown float64 `json:"ExecutionLimitDown"`
-		ExecutionLimitUp   float64 `json:"ExecutionLimitUp"`
-		High               float64 `json:"High"`
-		Last               float64 `json:"Last"`
-		LastQuantity       float64 `json:"LastQuantity"`
-		Low                float64 `json:"Low"`
-		Open               float64 `json:"Open"`
-		PrevCls            float64 `json:"PrevCls"`
-		Timestamp          int64   `json:"Timestamp"`
-		Volume             float64 `json:"Volume"`
-		Volume24H          float64 `json:"Volume24H"`
-	} `json:"ticker"`
-}
-
-type OKCoin struct {
-	Date   string `json:"date"`
-	Ticker struct {
-		Buy  string `json:"buy"`
-		High string `json:"high"`
-		Last string `json:"last"`
-		Low  string `json:"low"`
-		Sell string `json:"sell"`
-		Vol  string `json:"vol"`
-	} `json:"ticker"`
+// Price of an asset on an exchange compared to a reference exchange
+type Premium struct {
+	Exchange                string  `json:"exchange"`
+	Pair                    string  `json:"pair"`
+	Base                    string  `json:"-"`
+	Quote                   string  `json:"-"`
+	Price                   float64 `json:"price"`
+	ReferenceExchange       string  `json:"referenceExchange"`
+	ReferencePair           string  `json:"referencePair"`
+	ReferencePrice          float64 `json:"referencePrice"`
+	FiatRate                float64 `json:"fiatRate"`
+	ConvertedReferencePrice float64 `json:"convertedReferencePrice"`
+	Premium                 float64 `json:"premium"`
+	DateUpdated             string  `json:"dateUpdated"`
+}
+
+// Duration written to and read from JSON as a string, eg. "24h"
+type jsonDuration time.Duration
+
+func (d jsonDuration) MarshalJSON() ([]byte, error) {
+	if d == 0 {
+		return json.Marshal("")
+	}
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *jsonDuration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	if len(value) == 0 {
+		*d = 0
+		return nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = jsonDuration(parsed)
+	return nil
+}
+
+// Rule that triggers a webhook when a price condition starts to hold
+type AlertRule struct {
+	ID        int64        `json:"id"`
+	Name      string       `json:"name"`
+	Source    string       `json:"source"`
+	Kind      string       `json:"kind"`
+	Exchange  string       `json:"exchange"`
+	Pair      string       `json:"pair"`
+	Operator  string       `json:"operator"`
+	Threshold float64      `json:"threshold"`
+	Window    jsonDuration `json:"window,omitempty"`
+	IndexPair string       `json:"indexPair,omitempty"`
+	Webhook   string       `json:"webhook"`
+	Secret    string       `json:"secret,omitempty"`
+	Enabled   bool         `json:"enabled"`
+}
+
+// Body of an alert webhook
+type AlertEvent struct {
+	Rule        *AlertRule `json:"rule"`
+	Value       float64    `json:"value"`
+	Previous    float64    `json:"previous"`
+	Message     string     `json:"message"`
+	TriggeredAt string     `json:"triggeredAt"`
 }
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Ranges that aren't covered by the net.IP checks: "this network" and
+// carrier-grade NAT, which some clouds serve their metadata from
+var internalNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// Whether webhooks of rules added through the API may not be sent to an
+// address, so the API can't be used to reach the host's own network
+func internalAddress(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, network := range internalNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks that the host of a webhook resolves to public addresses only
+func checkWebhookHost(webhook string) error {
+	u, err := url.Parse(webhook)
+	if err != nil {
+		return err
+	}
+
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil {
+		return errors.New("webhook host can't be resolved: " + u.Hostname())
+	}
+	for _, ip := range ips {
+		if internalAddress(ip) {
+			return errors.New("webhook must not point to a loopback, link-local or private address")
+		}
+	}
+	return nil
+}
+
+// Refuses connections to internal addresses. It runs after the host name
+// is resolved, for every connection including redirects, so a host can't
+// pass checkWebhookHost and later resolve elsewhere.
+func refuseInternalAddress(network string, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || internalAddress(ip) {
+		return errors.New("refusing to send a webhook to " + host)
+	}
+	return nil
+}
+
+// Client for the webhooks of a rule. Rules from the config file are
+// trusted to reach any address.
+func webhookClient(source string) *http.Client {
+	client := &http.Client{Timeout: config.RequestTimeout}
+	if source != alertSourceConfig {
+		dialer := &net.Dialer{Timeout: config.RequestTimeout, Control: refuseInternalAddress}
+		client.Transport = &http.Transport{DialContext: dialer.DialContext}
+	}
+	return client
+}
+
+// Posts an alert to its webhook, retrying with backoff on failure
+func deliverAlert(webhook string, secret string, event AlertEvent) {
+	// Never send the secret along with the rule
+	rule := *event.Rule
+	rule.Secret = ""
+	event.Rule = &rule
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	if len(secret) == 0 {
+		secret = config.AlertSecret
+	}
+	if len(secret) == 0 {
+		log.Warningf("Alert %s has no secret and alertSecret is not set, sending it unsigned", rule.Name)
+	}
+
+	client := webhookClient(rule.Source)
+
+	for attempt := 0; ; attempt++ {
+		wait, err := postWebhook(client, webhook, secret, body, attempt)
+		if err == nil {
+			log.Infof("Delivered alert %s", rule.Name)
+			return
+		}
+
+		if attempt >= config.Retries || wait < 0 {
+			log.Errorf("Giving up on alert %s after %d attempts: %s", rule.Name, attempt+1, err.Error())
+			return
+		}
+		log.Warningf("Alert %s: %s", rule.Name, err.Error())
+		time.Sleep(wait)
+	}
+}
+
+// Posts a signed body to a webhook. On failure, returns how long to wait
+// before retrying, or a negative duration if retrying won't help.
+//
+// The X-Signature header holds "sha256=" followed by the hex HMAC-SHA256
+// of the X-Timestamp header, a dot and the body, keyed with the secret.
+func postWebhook(client *http.Client, webhook string, secret string, body []byte, attempt int) (time.Duration, error) {
+	req, err := http.NewRequest("POST", webhook, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "kyco.bitcoin.currency.tickers")
+	req.Header.Set("X-Timestamp", timestamp)
+	if len(secret) > 0 {
+		req.Header.Set("X-Signature", "sha256="+signPayload(secret, timestamp, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return retryBackoff(attempt), err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return retryAfter(resp, attempt), errors.New(webhook + " returned " + resp.Status)
+	}
+	return -1, errors.New(webhook + " returned " + resp.Status)
+}
+
+// Signs a webhook body along with its timestamp
+func signPayload(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestInternalAddress(t *testing.T) {
+	cases := []struct {
+		ip       string
+		internal bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"0.0.0.0", true},
+		{"0.1.2.3", true},
+		{"100.64.0.1", true},
+		{"100.100.100.200", true},
+		{"100.127.255.255", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:100.64.0.1", true},
+		{"224.0.0.1", true},
+		{"100.63.255.255", false},
+		{"100.128.0.0", false},
+		{"93.184.215.14", false},
+		{"2606:2800:21f:cb07:6820:80da:af6b:8b2c", false},
+	}
+
+	for _, c := range cases {
+		if got := internalAddress(net.ParseIP(c.ip)); got != c.internal {
+			t.Errorf("internalAddress(%s) = %t, want %t", c.ip, got, c.internal)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Rows held for a client before it is dropped as too slow
+	wsSendBuffer = 256
+	// Time allowed to write a message to a client
+	wsWriteWait = 10 * time.Second
+	// Time allowed between pongs from a client
+	wsPongWait = 60 * time.Second
+	// How often clients are pinged, must be less than wsPongWait
+	wsPingPeriod = wsPongWait * 9 / 10
+	// Largest message accepted from a client
+	wsMaxMessageSize = 4096
+)
+
+// The API is public and read only, so any origin may connect
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// Message sent by a client to change its channels
+type wsRequest struct {
+	Action   string   `json:"action"`
+	Channels []string `json:"channels"`
+}
+
+// Message sent to a client
+type wsMessage struct {
+	Type     string     `json:"type"`
+	Channel  string     `json:"channel,omitempty"`
+	Channels []string   `json:"channels,omitempty"`
+	Data     *APIStruct `json:"data,omitempty"`
+	Message  string     `json:"message,omitempty"`
+}
+
+// Channel a row is published on, "Exchange/BASE-QUOTE". Rows without a
+// pair use their currency code instead, and are only matched by
+// "Exchange/*" and "*/*".
+func quoteChannel(row *APIStruct) string {
+	if len(row.Base) > 0 && len(row.Quote) > 0 {
+		return row.Exchange + "/" + row.Base + "-" + row.Quote
+	}
+	return row.Exchange + "/" + row.CurrencyCode
+}
+
+// Maps a channel given by a client onto the form used by quoteChannel.
+// Either side may be "*" to match any exchange or pair.
+func parseChannel(channel string) (string, error) {
+	parts := strings.Split(channel, "/")
+	if len(parts) != 2 {
+		return "", errors.New("Channel must look like exchange/pair: " + channel)
+	}
+
+	exchange := parts[0]
+	if exchange != "*" {
+		e, err := lookupExchange(exchange)
+		if err != nil {
+			return "", errors.New(err.Error() + ": " + exchange)
+		}
+		exchange = e.Name()
+	}
+
+	pair := parts[1]
+	if pair != "*" {
+		p, err := parsePair(pair)
+		if err != nil {
+			return "", err
+		}
+		pair = p.String()
+	}
+
+	return exchange + "/" + pair, nil
+}
+
+// Channels a client is subscribed to
+type channelSet struct {
+	lock     sync.RWMutex
+	channels map[string]bool
+}
+
+// Whether a row is on one of the channels
+func (c *channelSet) match(row *APIStruct) bool {
+	channel := quoteChannel(row)
+	exchange := channel[:strings.Index(channel, "/")]
+	pair := channel[len(exchange)+1:]
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.channels[channel] || c.channels[exchange+"/*"] || c.channels["*/"+pair] || c.channels["*/*"]
+}
+
+// Adds or removes channels, returning the channels as they were parsed
+func (c *channelSet) update(channels []string, subscribe bool) ([]string, error) {
+	var parsed []string
+	for _, channel := range channels {
+		p, err := parseChannel(channel)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, p)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, p := range parsed {
+		if subscribe {
+			c.channels[p] = true
+		} else {
+			delete(c.channels, p)
+		}
+	}
+	return parsed, nil
+}
+
+// Streams new quotes over a WebSocket. Clients send
+// {"action":"subscribe","channels":["Luno/BTC-ZAR"]} and
+// {"action":"unsubscribe",...} to choose their channels, and receive a
+// {"type":"quote",...} message for every row written on them.
+func streamWebSocket(w http.ResponseWriter, req *http.Request) {
+	conn, err := wsUpgrader.Upgrade(w, req, nil)
+	if err != nil {
+		// Upgrade has already replied to the client
+		log.Warningf("WebSocket: %s", err.Error())
+		return
+	}
+	defer conn.Close()
+
+	log.Infof("Called: ws %s\n", req.RemoteAddr)
+
+	channels := &channelSet{channels: make(map[string]bool)}
+	sub := quoteFeed.subscribe(wsSendBuffer, channels.match)
+	defer quoteFeed.unsubscribe(sub)
+
+	// Replies to the client's requests, handed to the writer so that only
+	// one goroutine ever writes to the connection
+	replies := make(chan wsMessage, 16)
+	done := make(chan struct{})
+	go wsReadLoop(conn, channels, replies, done)
+
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case row, ok := <-sub.C:
+			if !ok {
+				if sub.Lagged() {
+					wsClose(conn, websocket.ClosePolicyViolation, "Too slow, reconnect and resubscribe")
+				}
+				return
+			}
+			if err := wsWrite(conn, wsMessage{Type: "quote", Channel: quoteChannel(row), Data: row}); err != nil {
+				return
+			}
+
+		case reply := <-replies:
+			if err := wsWrite(conn, reply); err != nil {
+				return
+			}
+
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+
+		case <-done:
+			return
+		}
+	}
+}
+
+// Reads the requests of a client until the connection goes away
+func wsReadLoop(conn *websocket.Conn, channels *channelSet, replies chan<- wsMessage, done chan<- struct{}) {
+	defer close(done)
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		var request wsRequest
+		if err := conn.ReadJSON(&request); err != nil {
+			if _, ok := err.(*websocket.CloseError); !ok && !errors.Is(err, websocket.ErrCloseSent) {
+				log.Debugf("WebSocket: %s", err.Error())
+			}
+			return
+		}
+
+		var reply wsMessage
+		switch request.Action {
+		case "subscribe", "unsubscribe":
+			parsed, err := channels.update(request.Channels, request.Action == "subscribe")
+			if err != nil {
+				reply = wsMessage{Type: "error", Message: err.Error()}
+				break
+			}
+			reply = wsMessage{Type: request.Action + "d", Channels: parsed}
+		default:
+			reply = wsMessage{Type: "error", Message: "action must be subscribe or unsubscribe"}
+		}
+
+		select {
+		case replies <- reply:
+		default:
+			// A client flooding us with requests isn't reading its replies
+			log.Warningf("WebSocket: dropping %s, too many requests", conn.RemoteAddr())
+			return
+		}
+	}
+}
+
+// Writes a message to a client
+func wsWrite(conn *websocket.Conn, message wsMessage) error {
+	conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return conn.WriteJSON(message)
+}
+
+// Tells a client why it is being disconnected
+func wsClose(conn *websocket.Conn, code int, reason string) {
+	conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason))
+}